@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const changeTokenPrefix = "movie-changes:"
+
+var errInvalidChangeToken = errors.New("invalid change token")
+
+// encodeChangeToken hides the sequence number of the change feed behind an opaque continuation token
+func encodeChangeToken(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(changeTokenPrefix + strconv.FormatInt(seq, 10)))
+}
+
+func decodeChangeToken(token string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(decoded), changeTokenPrefix) {
+		return 0, errInvalidChangeToken
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(string(decoded), changeTokenPrefix), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, errInvalidChangeToken
+	}
+	return seq, nil
+}
+
+// listMovieChangesHandler returns the movies created, updated and deleted since the given token in commit order.
+// Clients start without a token to get the whole catalogue, then resume from the returned token, calling again
+// right away while has_more is set
+func (app *application) listMovieChangesHandler(c *gin.Context) {
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	since := app.readString(qs, "since", "")
+	limit := app.readInt(qs, "limit", 100, v)
+
+	var seq int64
+	if since != "" {
+		var err error
+		seq, err = decodeChangeToken(since)
+		v.Check(err == nil, "since", "must be a token returned by a previous call")
+	}
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 1000, "limit", "must be a maximum of 1000")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	// only editors hear about movies the public can't see, for anyone else they are deleted
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	// one extra change tells whether there are more to come
+	changes, err := app.models.Changes.GetAll(seq, limit+1)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	if len(changes) > 0 {
+		seq = changes[len(changes)-1].Seq
+	}
+	if !canSeeUnpublished {
+		for i := range changes {
+			changes[i] = changes[i].ForPublic()
+		}
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data":     changes,
+		"next":     encodeChangeToken(seq),
+		"has_more": hasMore,
+	})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// readCollection fetches the collection addressed by the request, writing the error response when it can't
+func (app *application) readCollection(c *gin.Context) (*data.Collection, bool) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	collection, err := app.models.Collections.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return collection, true
+}
+
+// loadCollectionMovies embeds the movies of the collection the request user can see in order
+func (app *application) loadCollectionMovies(c *gin.Context, collection *data.Collection) error {
+	movieIds, err := app.models.Collections.GetMovieIds(collection.Id)
+	if err != nil {
+		return err
+	}
+
+	collection.Movies = make([]*data.Movie, 0, len(movieIds))
+	return app.embedMovies(c, movieIds, func(i int, movie *data.Movie) {
+		if movie != nil {
+			collection.Movies = append(collection.Movies, movie)
+		}
+	})
+}
+
+func (app *application) createCollectionHandler(c *gin.Context) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	collection := &data.Collection{
+		Name:        input.Name,
+		Description: input.Description,
+	}
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.Insert(collection)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(collection.Id, collection.Version))
+	c.JSON(http.StatusCreated, collection)
+}
+
+func (app *application) showCollectionHandler(c *gin.Context) {
+	collection, ok := app.readCollection(c)
+	if !ok {
+		return
+	}
+
+	// the embedded movies change without the collection version changing, like the responses with include the
+	// collection is neither tagged nor revalidated
+	err := app.loadCollectionMovies(c, collection)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+func (app *application) updateCollectionHandler(c *gin.Context) {
+	collection, ok := app.readCollection(c)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(collection.Id, collection.Version)) {
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	collection.Name = input.Name
+	collection.Description = input.Description
+
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.Update(collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(collection.Id, collection.Version))
+	c.JSON(http.StatusOK, collection)
+}
+
+func (app *application) deleteCollectionHandler(c *gin.Context) {
+	collection, ok := app.readCollection(c)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(collection.Id, collection.Version)) {
+		return
+	}
+
+	err := app.models.Collections.Delete(collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) listCollectionsHandler(c *gin.Context) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafeList = []string{"id", "name", "-id", "-name"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	collections, metadata, err := app.models.Collections.GetAll(input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     collections,
+	})
+}
+
+// setCollectionMoviesHandler replaces the ordered membership of the collection, adding, removing and
+// reordering movies in a single request
+func (app *application) setCollectionMoviesHandler(c *gin.Context) {
+	collection, ok := app.readCollection(c)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(collection.Id, collection.Version)) {
+		return
+	}
+
+	var input struct {
+		MovieIds []int64 `json:"movie_ids"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.MovieIds != nil, "movie_ids", "must be provided")
+	v.Check(len(input.MovieIds) <= data.MaxCollectionMovies, "movie_ids", fmt.Sprintf("must not contain more than %d movies", data.MaxCollectionMovies))
+	v.Check(validator.Unique(input.MovieIds), "movie_ids", "must not contain duplicate movies")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	movies, err := app.models.Movies.GetByIds(input.MovieIds, "id")
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if len(movies) != len(input.MovieIds) {
+		v.AddError("movie_ids", "must only refer to existing movies")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Collections.SetMovies(collection, input.MovieIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	err = app.loadCollectionMovies(c, collection)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(collection.Id, collection.Version))
+	c.JSON(http.StatusOK, collection)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/imdb"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// canonicalizeGenresCommand rewrites the genres of every movie to canonical slugs,
+// movies with unknown genres are reported and left untouched
+func (app *application) canonicalizeGenresCommand() error {
+	index, err := app.models.Genres.Index()
+	if err != nil {
+		return err
+	}
+
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafeList: []string{"id"}}
+	updated, skipped := 0, 0
+	for {
+		movies, metadata, err := app.models.Movies.GetAll(data.MovieSearch{}, filters)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			canonical, unknown := index.Canonicalize(movie.Genres)
+			if len(unknown) > 0 {
+				skipped++
+				app.logger.Info("skipping movie with unknown genres", map[string]string{
+					"movie_id": strconv.FormatInt(movie.Id, 10),
+					"genres":   data.UnknownGenresMessage(unknown),
+				})
+				continue
+			}
+			if strings.Join(canonical, ",") == strings.Join(movie.Genres, ",") {
+				continue
+			}
+
+			movie.Genres = canonical
+			err = app.models.Movies.Update(movie)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrEditConflict):
+					skipped++
+					app.logger.Info("skipping movie edited concurrently", map[string]string{
+						"movie_id": strconv.FormatInt(movie.Id, 10),
+					})
+					continue
+				default:
+					return err
+				}
+			}
+			updated++
+		}
+
+		if filters.Page >= metadata.LastPage {
+			break
+		}
+		filters.Page++
+	}
+
+	app.logger.Info("genres canonicalized", map[string]string{
+		"updated": strconv.Itoa(updated),
+		"skipped": strconv.Itoa(skipped),
+	})
+	return nil
+}
+
+// grantPermissionsCommand grants permissions such as movies:write to the user with the given email
+func (app *application) grantPermissionsCommand(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: grant-permissions <email> <permission>...")
+	}
+
+	user, err := app.models.User.GetByEmail(args[0])
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return fmt.Errorf("no user with email %q", args[0])
+		default:
+			return err
+		}
+	}
+
+	err = app.models.Permissions.AddForUser(user.Id, args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.Id)
+	if err != nil {
+		return err
+	}
+	app.logger.Info("permissions granted", map[string]string{
+		"email":       user.Email,
+		"permissions": strings.Join(permissions, ","),
+	})
+	return nil
+}
+
+// importCommand loads movies from an outside dataset, see importIMDbCommand
+func (app *application) importCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: import <dataset> [options], datasets: imdb")
+	}
+
+	switch args[0] {
+	case "imdb":
+		return app.importIMDbCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown dataset %q", args[0])
+	}
+}
+
+// importIMDbCommand upserts the movies of an IMDb title.basics.tsv.gz dump, matching them by their IMDb id.
+// Titles of other types and adult titles are skipped, genres missing from the taxonomy are dropped,
+// and titles which fail validation are reported as rejects
+func (app *application) importIMDbCommand(args []string) error {
+	fs := flag.NewFlagSet("import imdb", flag.ContinueOnError)
+	batchSize := fs.Int("batch-size", 1000, "Number of movies upserted per transaction")
+	titleTypes := fs.String("title-types", "movie,tvMovie", "Comma-separated IMDb title types to import")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *batchSize < 1 {
+		return errors.New("usage: import imdb [-batch-size n] [-title-types list] <title.basics.tsv.gz>")
+	}
+	types := strings.Split(*titleTypes, ",")
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := imdb.NewReader(file)
+	if err != nil {
+		return err
+	}
+
+	index, err := app.models.Genres.Index()
+	if err != nil {
+		return err
+	}
+
+	// titles keep their known genres, droppedGenres counts the titles which lost unknown ones
+	inserted, updated, skipped, rejected, droppedGenres := 0, 0, 0, 0, 0
+	reject := func(tconst string, line int, reason string) {
+		rejected++
+		app.logger.Info("rejecting imdb title", map[string]string{
+			"tconst": tconst,
+			"line":   strconv.Itoa(line),
+			"reason": reason,
+		})
+	}
+
+	var externalIds []string
+	var movies []*data.Movie
+	flush := func() error {
+		if len(movies) == 0 {
+			return nil
+		}
+		batchInserted, batchUpdated, err := app.models.Movies.UpsertByExternalId("imdb", externalIds, movies)
+		if err != nil {
+			return err
+		}
+		inserted += batchInserted
+		updated += batchUpdated
+		externalIds, movies = externalIds[:0], movies[:0]
+
+		app.logger.Info("imdb import progress", map[string]string{
+			"line":           strconv.Itoa(reader.Line()),
+			"inserted":       strconv.Itoa(inserted),
+			"updated":        strconv.Itoa(updated),
+			"skipped":        strconv.Itoa(skipped),
+			"rejected":       strconv.Itoa(rejected),
+			"dropped_genres": strconv.Itoa(droppedGenres),
+		})
+		return nil
+	}
+
+	for {
+		title, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *imdb.RowError
+		if errors.As(err, &rowErr) {
+			reject(rowErr.Tconst, rowErr.Line, rowErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if title.IsAdult || !validator.In(title.TitleType, types...) {
+			skipped++
+			continue
+		}
+
+		genres, unknown := index.Canonicalize(title.Genres)
+		if len(unknown) > 0 {
+			dropped := make([]string, 0, len(unknown))
+			for genre := range unknown {
+				dropped = append(dropped, genre)
+			}
+			sort.Strings(dropped)
+
+			if len(genres) == 0 {
+				reject(title.Tconst, reader.Line(), "no known genre, unknown genres "+strings.Join(dropped, ", "))
+				continue
+			}
+			droppedGenres++
+			app.logger.Info("dropping unknown imdb genres", map[string]string{
+				"tconst": title.Tconst,
+				"line":   strconv.Itoa(reader.Line()),
+				"genres": strings.Join(dropped, ", "),
+			})
+		}
+		movie := &data.Movie{
+			Title:   title.PrimaryTitle,
+			Year:    title.StartYear,
+			Runtime: data.Runtime(title.RuntimeMinutes),
+			Genres:  genres,
+			// the dumps bootstrap the public catalogue, the status of movies already imported is left as is
+			Status: data.MoviePublished,
+		}
+
+		v := validator.New()
+		data.ValidateExternalId(v, &data.ExternalId{Source: "imdb", ExternalId: title.Tconst})
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			reject(title.Tconst, reader.Line(), validationMessage(v.Errors))
+			continue
+		}
+
+		externalIds = append(externalIds, title.Tconst)
+		movies = append(movies, movie)
+		if len(movies) >= *batchSize {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = flush()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("imdb import finished", map[string]string{
+		"inserted":       strconv.Itoa(inserted),
+		"updated":        strconv.Itoa(updated),
+		"skipped":        strconv.Itoa(skipped),
+		"rejected":       strconv.Itoa(rejected),
+		"dropped_genres": strconv.Itoa(droppedGenres),
+	})
+	return nil
+}
+
+// validationMessage flattens validation errors into a single line, sorted by field
+func validationMessage(errs map[string]string) string {
+	messages := make([]string, 0, len(errs))
+	for field, message := range errs {
+		messages = append(messages, field+" "+message)
+	}
+	sort.Strings(messages)
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userContextKey        = "user"
+	permissionsContextKey = "permissions"
+)
+
+func (app *application) contextSetUser(c *gin.Context, user *data.User) {
+	c.Set(userContextKey, user)
+}
+
+func (app *application) contextGetUser(c *gin.Context) *data.User {
+	user, ok := c.MustGet(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
+
+// contextGetPermissions returns the permissions of the request user, they are loaded once per request
+func (app *application) contextGetPermissions(c *gin.Context) (data.Permissions, error) {
+	if permissions, ok := c.Get(permissionsContextKey); ok {
+		return permissions.(data.Permissions), nil
+	}
+
+	user := app.contextGetUser(c)
+	if user.IsAnonymous() {
+		return data.Permissions{}, nil
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(permissionsContextKey, permissions)
+	return permissions, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func (app *application) listMovieCreditsHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	credits, err := app.models.Credits.GetForMovies([]int64{movie.Id})
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data": credits[movie.Id],
+	})
+}
+
+func (app *application) createMovieCreditHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	var input struct {
+		PersonId     int64  `json:"person_id"`
+		Role         string `json:"role"`
+		Character    string `json:"character"`
+		BillingOrder int32  `json:"billing_order"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	credit := &data.Credit{
+		MovieId:      movie.Id,
+		PersonId:     input.PersonId,
+		Role:         input.Role,
+		Character:    input.Character,
+		BillingOrder: input.BillingOrder,
+	}
+
+	v := validator.New()
+	if data.ValidateCredit(v, credit); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	person, err := app.models.People.Get(credit.PersonId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("person_id", "must refer to an existing person")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	err = app.models.Credits.Insert(credit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "this person is already credited in this role")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	credit.PersonName = person.Name
+
+	c.JSON(http.StatusCreated, credit)
+}
+
+func (app *application) deleteMovieCreditHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+	creditId := c.Param("credit_id")
+	_creditId, _ := strconv.ParseInt(creditId, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	err = app.models.Credits.Delete(movie.Id, _creditId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
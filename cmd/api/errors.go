@@ -10,7 +10,8 @@ func (app *application) logError(c *gin.Context, err error) {
 }
 
 func (app *application) errorResponse(c *gin.Context, status int, message interface{}) {
-	c.JSON(status, gin.H{"error": message})
+	// abort so an error written by a middleware stops the handler chain
+	c.AbortWithStatusJSON(status, gin.H{"error": message})
 }
 
 func (app *application) serverErrorResponse(c *gin.Context, err error) {
@@ -38,6 +39,60 @@ func (app *application) failedValidationResponse(c *gin.Context, err map[string]
 }
 
 func (app *application) editConflictResponse(c *gin.Context) {
+	// the client sent the version it last saw, so the conflict means its precondition failed
+	if c.GetHeader("If-Match") != "" {
+		app.preconditionFailedResponse(c)
+		return
+	}
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(c, http.StatusUnprocessableEntity, message)
 }
+
+func (app *application) conflictResponse(c *gin.Context, err error) {
+	app.errorResponse(c, http.StatusConflict, err.Error())
+}
+
+// duplicateMovieResponse reports the existing movies which look like the one being created
+func (app *application) duplicateMovieResponse(c *gin.Context, candidates []int64) {
+	message := map[string]interface{}{
+		"message":    "this movie looks like a duplicate of an existing movie, retry with force=true to create it anyway",
+		"candidates": candidates,
+	}
+	app.errorResponse(c, http.StatusConflict, message)
+}
+
+func (app *application) preconditionFailedResponse(c *gin.Context) {
+	message := "the record has been modified since you last fetched it, please fetch it again"
+	app.errorResponse(c, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(c *gin.Context) {
+	message := "this request must be conditional, please provide an If-Match header"
+	app.errorResponse(c, http.StatusPreconditionRequired, message)
+}
+
+func (app *application) shuttingDownResponse(c *gin.Context) {
+	message := "the server is shutting down, please try again later"
+	app.errorResponse(c, http.StatusServiceUnavailable, message)
+}
+
+func (app *application) invalidCredentialsResponse(c *gin.Context) {
+	message := "invalid authentication credentials"
+	app.errorResponse(c, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(c *gin.Context) {
+	c.Header("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	app.errorResponse(c, http.StatusUnauthorized, message)
+}
+
+func (app *application) authenticationRequiredResponse(c *gin.Context) {
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(c, http.StatusUnauthorized, message)
+}
+
+func (app *application) notPermittedResponse(c *gin.Context) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(c, http.StatusForbidden, message)
+}
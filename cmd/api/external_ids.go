@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func (app *application) showMovieByExternalIdHandler(c *gin.Context) {
+	source := c.Param("source")
+	externalId := c.Param("id")
+
+	v := validator.New()
+	if data.ValidateExternalId(v, &data.ExternalId{Source: source, ExternalId: externalId}); !v.Valid() {
+		app.notFoundResponse(c)
+		return
+	}
+
+	movieId, err := app.models.ExternalIds.GetMovieId(source, externalId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	app.showMovie(c, movieId)
+}
+
+func (app *application) listMovieExternalIdsHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	externalIds, err := app.models.ExternalIds.GetForMovies([]int64{movie.Id})
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data": externalIds[movie.Id],
+	})
+}
+
+func (app *application) setMovieExternalIdHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	var input struct {
+		ExternalId string `json:"external_id"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	externalId := &data.ExternalId{
+		MovieId:    movie.Id,
+		Source:     c.Param("source"),
+		ExternalId: input.ExternalId,
+	}
+
+	v := validator.New()
+	if data.ValidateExternalId(v, externalId); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.ExternalIds.Set(externalId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalId):
+			v.AddError("external_id", "is already mapped to another movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, externalId)
+}
+
+func (app *application) deleteMovieExternalIdHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	err := app.models.ExternalIds.Delete(_id, c.Param("source"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
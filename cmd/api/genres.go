@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// validateGenreConflicts reports the slug, name and aliases of genre which already belong to another genre
+func (app *application) validateGenreConflicts(v *validator.Validator, genre *data.Genre) error {
+	conflicts, err := app.models.Genres.Conflicts(genre)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		v.AddError("aliases", fmt.Sprintf("already used by another genre: %s", strings.Join(conflicts, ", ")))
+	}
+	return nil
+}
+
+func (app *application) createGenreHandler(c *gin.Context) {
+	var input struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Slug:    input.Slug,
+		Name:    input.Name,
+		Aliases: input.Aliases,
+	}
+	if genre.Aliases == nil {
+		genre.Aliases = []string{}
+	}
+
+	v := validator.New()
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.validateGenreConflicts(v, genre)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(genre.Id, genre.Version))
+	c.JSON(http.StatusCreated, genre)
+}
+
+func (app *application) showGenreHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	genre, err := app.models.Genres.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	if app.checkIfNoneMatch(c, etag(genre.Id, genre.Version)) {
+		return
+	}
+	c.JSON(http.StatusOK, genre)
+}
+
+func (app *application) updateGenreHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+	genre, err := app.models.Genres.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(genre.Id, genre.Version)) {
+		return
+	}
+
+	var input struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	genre.Slug = input.Slug
+	genre.Name = input.Name
+	genre.Aliases = input.Aliases
+
+	v := validator.New()
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.validateGenreConflicts(v, genre)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "a genre with this slug already exists")
+			app.failedValidationResponse(c, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(genre.Id, genre.Version))
+	c.JSON(http.StatusOK, genre)
+}
+
+func (app *application) deleteGenreHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+	genre, err := app.models.Genres.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(genre.Id, genre.Version)) {
+		return
+	}
+
+	err = app.models.Genres.Delete(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrGenreInUse):
+			app.conflictResponse(c, errors.New("the genre is still used by movies, retag them first"))
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) listGenresHandler(c *gin.Context) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data": genres,
+	})
+}
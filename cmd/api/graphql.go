@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"net/http"
+	"strconv"
+)
+
+// graphqlDefaultListSize is the number of items assumed for list fields without a size argument when
+// computing the complexity of a query
+const graphqlDefaultListSize = 10
+
+type graphqlContextKey struct{}
+
+// graphqlRequest is the per-request state the resolvers share
+type graphqlRequest struct {
+	c                 *gin.Context
+	canSeeUnpublished bool
+	loaders           *graphqlLoaders
+}
+
+func graphqlRequestFrom(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlContextKey{}).(*graphqlRequest)
+}
+
+// graphqlError is an error reported to GraphQL clients, code mirrors the status of the REST error responses
+type graphqlError struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]interface{} {
+	return e.extensions
+}
+
+func newGraphQLError(code, message string) *graphqlError {
+	return &graphqlError{message: message, extensions: map[string]interface{}{"code": code}}
+}
+
+// graphqlServerError logs err and hides it from the client, like serverErrorResponse
+func (app *application) graphqlServerError(c *gin.Context, err error) error {
+	app.logError(c, err)
+	return newGraphQLError("INTERNAL_SERVER_ERROR", "the server encountered a problem and could not process your request")
+}
+
+func graphqlNotFoundError() error {
+	return newGraphQLError("NOT_FOUND", "the requested resource could not be found")
+}
+
+func graphqlFailedValidationError(errors map[string]string) error {
+	err := newGraphQLError("FAILED_VALIDATION", "the input is invalid")
+	err.extensions["errors"] = errors
+	return err
+}
+
+func graphqlEditConflictError() error {
+	return newGraphQLError("EDIT_CONFLICT", "the record has been modified since you last fetched it, please fetch it again")
+}
+
+func graphqlPreconditionRequiredError() error {
+	return newGraphQLError("PRECONDITION_REQUIRED", "this mutation must be conditional, please provide the version")
+}
+
+func graphqlDuplicateMovieError(candidates []int64) error {
+	err := newGraphQLError("DUPLICATE_MOVIE", "this movie looks like a duplicate of an existing movie, retry with force: true to create it anyway")
+	err.extensions["candidates"] = candidates
+	return err
+}
+
+func graphqlAuthenticationRequiredError() error {
+	return newGraphQLError("UNAUTHENTICATED", "you must be authenticated to access this resource")
+}
+
+func graphqlNotPermittedError() error {
+	return newGraphQLError("FORBIDDEN", "your user account doesn't have the necessary permissions to access this resource")
+}
+
+// graphqlHandler executes GraphQL queries and mutations sent as JSON. Documents which don't parse, don't
+// validate against the schema or are over the depth or complexity limits are rejected with 400 before any
+// resolver runs, errors raised while executing are reported next to the data with 200, as GraphQL does
+func (app *application) graphqlHandler() gin.HandlerFunc {
+	schema, err := app.graphqlSchema()
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		var input struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		err := app.readJSON(c, &input)
+		if err != nil {
+			app.badRequestResponse(c, err)
+			return
+		}
+
+		v := validator.New()
+		if v.Check(input.Query != "", "query", "must be provided"); !v.Valid() {
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+
+		doc, err := parser.Parse(parser.ParseParams{Source: input.Query})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+			return
+		}
+		validation := graphql.ValidateDocument(&schema, doc, nil)
+		if !validation.IsValid {
+			c.JSON(http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+			return
+		}
+		err = app.checkGraphQLLimits(&schema, doc, input.OperationName, input.Variables)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+			return
+		}
+
+		canSeeUnpublished, err := app.canSeeUnpublished(c)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), graphqlContextKey{}, &graphqlRequest{
+			c:                 c,
+			canSeeUnpublished: canSeeUnpublished,
+			loaders:           app.newGraphQLLoaders(canSeeUnpublished),
+		})
+		result := graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           doc,
+			OperationName: input.OperationName,
+			Args:          input.Variables,
+			Context:       ctx,
+		})
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// checkGraphQLLimits rejects the operation when it nests fields deeper than the maximum depth or when its
+// complexity is over the maximum. Every field costs 1 and the fields below a list field count once per item
+// the list may hold, its first or pageSize argument or graphqlDefaultListSize. Introspection is limited as well
+func (app *application) checkGraphQLLimits(schema *graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	var operation *ast.OperationDefinition
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		}
+	}
+	// an unknown operation is reported when executing
+	if operation == nil {
+		return nil
+	}
+
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+
+	cost := graphqlCost{
+		schema:    schema,
+		fragments: fragments,
+		variables: variables,
+		maxDepth:  app.config.graphql.maxDepth,
+	}
+	depth, complexity := cost.selectionSet(operation.SelectionSet, root, 1)
+	if depth > app.config.graphql.maxDepth {
+		return fmt.Errorf("query is nested deeper than the maximum depth of %d", app.config.graphql.maxDepth)
+	}
+	if complexity > app.config.graphql.maxComplexity {
+		return fmt.Errorf("query has a complexity of %d, over the maximum of %d", complexity, app.config.graphql.maxComplexity)
+	}
+	return nil
+}
+
+var graphqlMetaFields = map[string]*graphql.FieldDefinition{
+	"__schema":   graphql.SchemaMetaFieldDef,
+	"__type":     graphql.TypeMetaFieldDef,
+	"__typename": graphql.TypeNameMetaFieldDef,
+}
+
+type graphqlCost struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	maxDepth  int
+}
+
+// selectionSet returns the depth and complexity of the selection set of parent at level, it stops
+// descending below the maximum depth as the query is rejected anyway
+func (g *graphqlCost) selectionSet(set *ast.SelectionSet, parent *graphql.Object, level int) (depth, complexity int) {
+	if set == nil || parent == nil {
+		return level - 1, 0
+	}
+	if level > g.maxDepth {
+		return level, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, cost int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d, cost = g.field(selection, parent, level)
+		case *ast.InlineFragment:
+			d, cost = g.selectionSet(selection.SelectionSet, g.typeCondition(selection.TypeCondition, parent), level)
+		case *ast.FragmentSpread:
+			fragment, ok := g.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			d, cost = g.selectionSet(fragment.SelectionSet, g.typeCondition(fragment.TypeCondition, parent), level)
+		}
+		depth = max(depth, d)
+		complexity += cost
+	}
+	return depth, complexity
+}
+
+func (g *graphqlCost) field(field *ast.Field, parent *graphql.Object, level int) (depth, complexity int) {
+	definition, ok := parent.Fields()[field.Name.Value]
+	if !ok {
+		// the introspection fields are not part of the object fields but are limited like any other
+		definition, ok = graphqlMetaFields[field.Name.Value]
+	}
+	if !ok {
+		return level, 1
+	}
+
+	items := 1
+	fieldType := definition.Type
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	if _, ok := fieldType.(*graphql.List); ok {
+		items = g.listSize(field, definition)
+	}
+
+	child, _ := graphql.GetNamed(definition.Type).(*graphql.Object)
+	depth, complexity = g.selectionSet(field.SelectionSet, child, level+1)
+	return max(depth, level), 1 + items*complexity
+}
+
+// listSize returns the number of items a list field may hold
+func (g *graphqlCost) listSize(field *ast.Field, definition *graphql.FieldDefinition) int {
+	for _, arg := range definition.Args {
+		if arg.Name() != "first" && arg.Name() != "pageSize" {
+			continue
+		}
+
+		size, _ := arg.DefaultValue.(int)
+		for _, value := range field.Arguments {
+			if value.Name.Value != arg.Name() {
+				continue
+			}
+			switch value := value.Value.(type) {
+			case *ast.IntValue:
+				size, _ = strconv.Atoi(value.Value)
+			case *ast.Variable:
+				switch variable := g.variables[value.Name.Value].(type) {
+				case float64:
+					size = int(variable)
+				case int:
+					size = variable
+				}
+			}
+		}
+		return max(size, 1)
+	}
+	return graphqlDefaultListSize
+}
+
+func (g *graphqlCost) typeCondition(condition *ast.Named, parent *graphql.Object) *graphql.Object {
+	if condition == nil {
+		return parent
+	}
+	object, _ := g.schema.Type(condition.Name.Value).(*graphql.Object)
+	return object
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"time"
+)
+
+// batchLoader collects the keys the resolvers of a GraphQL query ask for and fetches them in a single call.
+// Resolvers return the thunk of load, graphql-go runs every resolver of a level before calling the thunks of
+// that level, so the first thunk called fetches the keys of the whole level. A loader lives for one request
+// and is only used from the goroutine executing it
+type batchLoader[K comparable, V any] struct {
+	fetch   func(keys []K) (map[K]V, error)
+	pending []K
+	queued  map[K]bool
+	results map[K]V
+	errors  map[K]error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		fetch:   fetch,
+		queued:  make(map[K]bool),
+		results: make(map[K]V),
+		errors:  make(map[K]error),
+	}
+}
+
+// load queues the key and returns a thunk resolving to its value, or to nil when there is none
+func (l *batchLoader[K, V]) load(key K) func() (interface{}, error) {
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+
+	return func() (interface{}, error) {
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+
+			results, err := l.fetch(keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errors[k] = err
+					continue
+				}
+				if value, ok := results[k]; ok {
+					l.results[k] = value
+				}
+			}
+		}
+
+		if err := l.errors[key]; err != nil {
+			return nil, err
+		}
+		value, ok := l.results[key]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
+
+// graphqlLoaders holds the loaders of a GraphQL request so related resources take one query per level of
+// the query rather than one per parent
+type graphqlLoaders struct {
+	movies  *batchLoader[int64, *data.Movie]
+	users   *batchLoader[int64, *data.User]
+	people  *batchLoader[int64, *data.Person]
+	credits *batchLoader[int64, []*data.Credit]
+	// reviews are loaded by the number of reviews asked for each movie
+	reviews map[int]*batchLoader[int64, []*data.Review]
+}
+
+func (app *application) newGraphQLLoaders(canSeeUnpublished bool) *graphqlLoaders {
+	return &graphqlLoaders{
+		movies: newBatchLoader(func(ids []int64) (map[int64]*data.Movie, error) {
+			movies, err := app.models.Movies.GetByIds(ids)
+			if err != nil {
+				return nil, err
+			}
+			now := time.Now()
+			moviesById := make(map[int64]*data.Movie, len(movies))
+			for _, movie := range movies {
+				if canSeeUnpublished || movie.IsPublic(now) {
+					moviesById[movie.Id] = movie
+				}
+			}
+			return moviesById, nil
+		}),
+		users: newBatchLoader(func(ids []int64) (map[int64]*data.User, error) {
+			users, err := app.models.User.GetByIds(ids)
+			if err != nil {
+				return nil, err
+			}
+			usersById := make(map[int64]*data.User, len(users))
+			for _, user := range users {
+				usersById[user.Id] = user
+			}
+			return usersById, nil
+		}),
+		people: newBatchLoader(func(ids []int64) (map[int64]*data.Person, error) {
+			people, err := app.models.People.GetByIds(ids)
+			if err != nil {
+				return nil, err
+			}
+			peopleById := make(map[int64]*data.Person, len(people))
+			for _, person := range people {
+				peopleById[person.Id] = person
+			}
+			return peopleById, nil
+		}),
+		credits: newBatchLoader(app.models.Credits.GetForMovies),
+		reviews: make(map[int]*batchLoader[int64, []*data.Review]),
+	}
+}
+
+// reviewsLoader returns the loader of the latest limit reviews of movies
+func (app *application) reviewsLoader(loaders *graphqlLoaders, limit int) *batchLoader[int64, []*data.Review] {
+	loader, ok := loaders.reviews[limit]
+	if !ok {
+		loader = newBatchLoader(func(ids []int64) (map[int64][]*data.Review, error) {
+			return app.models.Reviews.GetForMovies(ids, limit)
+		})
+		loaders.reviews[limit] = loader
+	}
+	return loader
+}
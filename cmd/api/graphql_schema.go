@@ -0,0 +1,678 @@
+package main
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"strconv"
+)
+
+// graphqlSchema builds the GraphQL schema, its types mirror the JSON representations of the REST API and its
+// resolvers apply the same validators and permission checks as the REST handlers
+func (app *application) graphqlSchema() (graphql.Schema, error) {
+	var movieType, reviewType *graphql.Object
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			// users only see their own email address
+			"email": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					user := p.Source.(*data.User)
+					if app.contextGetUser(graphqlRequestFrom(p.Context).c).Id != user.Id {
+						return nil, nil
+					}
+					return user.Email, nil
+				},
+			},
+		},
+	})
+
+	personType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Person",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"birthYear": &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	creditType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Credit",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"role":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"character":    &graphql.Field{Type: graphql.String},
+			"billingOrder": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"person": &graphql.Field{
+				Type: personType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					credit := p.Source.(*data.Credit)
+					return graphqlRequestFrom(p.Context).loaders.people.load(credit.PersonId), nil
+				},
+			},
+		},
+	})
+
+	reviewType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Review",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"rating":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"body":      &graphql.Field{Type: graphql.String},
+				"version":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"author": &graphql.Field{
+					Type: userType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						review := p.Source.(*data.Review)
+						return graphqlRequestFrom(p.Context).loaders.users.load(review.UserId), nil
+					},
+				},
+				"movie": &graphql.Field{
+					Type: movieType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						review := p.Source.(*data.Review)
+						return graphqlRequestFrom(p.Context).loaders.movies.load(review.MovieId), nil
+					},
+				},
+			}
+		}),
+	})
+
+	movieType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Movie",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":    &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"title": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"year":  &graphql.Field{Type: graphql.Int},
+				"runtime": &graphql.Field{
+					Type:        graphql.Int,
+					Description: "Runtime in minutes",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return int32(p.Source.(*data.Movie).Runtime), nil
+					},
+				},
+				"genres":        &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				"ratingAverage": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+				"ratingCount":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"status":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"publishAt":     &graphql.Field{Type: graphql.DateTime},
+				"unpublishAt":   &graphql.Field{Type: graphql.DateTime},
+				"version":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"createdBy": &graphql.Field{
+					Type: userType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						movie := p.Source.(*data.Movie)
+						if movie.CreatedBy == nil {
+							return nil, nil
+						}
+						return graphqlRequestFrom(p.Context).loaders.users.load(*movie.CreatedBy), nil
+					},
+				},
+				"credits": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(creditType))),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						movie := p.Source.(*data.Movie)
+						return graphqlRequestFrom(p.Context).loaders.credits.load(movie.Id), nil
+					},
+				},
+				"reviews": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reviewType))),
+					Description: "The latest reviews of the movie",
+					Args: graphql.FieldConfigArgument{
+						"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						movie := p.Source.(*data.Movie)
+						first := p.Args["first"].(int)
+
+						v := validator.New()
+						v.Check(first > 0, "first", "must be greater than zero")
+						v.Check(first <= 100, "first", "must be maximum of 100")
+						if !v.Valid() {
+							return nil, graphqlFailedValidationError(v.Errors)
+						}
+
+						loaders := graphqlRequestFrom(p.Context).loaders
+						return app.reviewsLoader(loaders, first).load(movie.Id), nil
+					},
+				},
+			}
+		}),
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movie": &graphql.Field{
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return graphqlRequestFrom(p.Context).loaders.movies.load(graphqlID(p.Args["id"])), nil
+				},
+			},
+			"movies": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(movieType))),
+				Args: graphql.FieldConfigArgument{
+					"title":        &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"genres":       &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"personId":     &graphql.ArgumentConfig{Type: graphql.ID},
+					"collectionId": &graphql.ArgumentConfig{Type: graphql.ID},
+					"status":       &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"owner":        &graphql.ArgumentConfig{Type: graphql.String, Description: "me lists the movies you created"},
+					"page":         &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"pageSize":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+					"sort":         &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "id"},
+				},
+				Resolve: app.resolveMovies,
+			},
+			"me": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					user := app.contextGetUser(graphqlRequestFrom(p.Context).c)
+					if user.IsAnonymous() {
+						return nil, nil
+					}
+					return user, nil
+				},
+			},
+		},
+	})
+
+	versionArg := &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "The version last seen, the mutation fails with EDIT_CONFLICT when the record changed since",
+	}
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": &graphql.Field{
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"title":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"year":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"runtime": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"genres":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+					"force":   &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: app.resolveCreateMovie,
+			},
+			"updateMovie": &graphql.Field{
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": versionArg,
+					"title":   &graphql.ArgumentConfig{Type: graphql.String},
+					"year":    &graphql.ArgumentConfig{Type: graphql.Int},
+					"runtime": &graphql.ArgumentConfig{Type: graphql.Int},
+					"genres":  &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: app.resolveUpdateMovie,
+			},
+			"deleteMovie": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": versionArg,
+				},
+				Resolve: app.resolveDeleteMovie,
+			},
+			"createReview": &graphql.Field{
+				Type: reviewType,
+				Args: graphql.FieldConfigArgument{
+					"movieId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"rating":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"body":    &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+				},
+				Resolve: app.resolveCreateReview,
+			},
+			"updateReview": &graphql.Field{
+				Type: reviewType,
+				Args: graphql.FieldConfigArgument{
+					"movieId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": versionArg,
+					"rating":  &graphql.ArgumentConfig{Type: graphql.Int},
+					"body":    &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: app.resolveUpdateReview,
+			},
+			"deleteReview": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"movieId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": versionArg,
+				},
+				Resolve: app.resolveDeleteReview,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+// graphqlID reads an ID argument, ids which aren't numbers are not found like in the REST routes
+func graphqlID(value interface{}) int64 {
+	id, _ := value.(string)
+	_id, _ := strconv.ParseInt(id, 10, 64)
+	return _id
+}
+
+// graphqlStrings reads a list of strings argument, nil when it wasn't given
+func graphqlStrings(value interface{}) []string {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		list = append(list, value.(string))
+	}
+	return list
+}
+
+// graphqlRequireAuthenticatedUser is the GraphQL counterpart of requireAuthenticatedUser
+func (app *application) graphqlRequireAuthenticatedUser(c *gin.Context) error {
+	if app.contextGetUser(c).IsAnonymous() {
+		return graphqlAuthenticationRequiredError()
+	}
+	return nil
+}
+
+// graphqlRequirePermission is the GraphQL counterpart of requirePermission
+func (app *application) graphqlRequirePermission(c *gin.Context, code string) error {
+	err := app.graphqlRequireAuthenticatedUser(c)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := app.contextGetPermissions(c)
+	if err != nil {
+		return app.graphqlServerError(c, err)
+	}
+	if !permissions.Include(code) {
+		return graphqlNotPermittedError()
+	}
+	return nil
+}
+
+// graphqlCheckVersion is the GraphQL counterpart of checkIfMatch, the version argument plays the part of the
+// If-Match header
+func (app *application) graphqlCheckVersion(p graphql.ResolveParams, version int32) error {
+	expected, ok := p.Args["version"].(int)
+	if !ok {
+		if app.config.requireIfMatch {
+			return graphqlPreconditionRequiredError()
+		}
+		return nil
+	}
+	if int32(expected) != version {
+		return graphqlEditConflictError()
+	}
+	return nil
+}
+
+// graphqlVisibleMovie fetches the movie a mutation refers to when the request user may see it, hidden movies
+// are not found like in readVisibleMovie
+func (app *application) graphqlVisibleMovie(c *gin.Context, id int64) (*data.Movie, error) {
+	movie, err := app.getVisibleMovie(c, id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFoundError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+	return movie, nil
+}
+
+// resolveMovies lists movies with the filters and visibility rules of listMovieHandler
+func (app *application) resolveMovies(p graphql.ResolveParams) (interface{}, error) {
+	req := graphqlRequestFrom(p.Context)
+
+	var input struct {
+		data.MovieSearch
+		data.Filters
+	}
+	input.Title = p.Args["title"].(string)
+	input.Genres = graphqlStrings(p.Args["genres"])
+	input.PersonId = graphqlID(p.Args["personId"])
+	input.CollectionId = graphqlID(p.Args["collectionId"])
+	input.Statuses = graphqlStrings(p.Args["status"])
+	owner, _ := p.Args["owner"].(string)
+	input.Filters.Page = p.Args["page"].(int)
+	input.Filters.PageSize = p.Args["pageSize"].(int)
+	input.Filters.Sort = p.Args["sort"].(string)
+	input.Filters.SortSafeList = movieSortSafeList
+
+	v := validator.New()
+	data.ValidateMovieSearch(v, input.MovieSearch)
+
+	v.Check(owner == "" || owner == "me", "owner", "must be me")
+	if owner == "me" {
+		err := app.graphqlRequireAuthenticatedUser(req.c)
+		if err != nil {
+			return nil, err
+		}
+		input.CreatedBy = app.contextGetUser(req.c).Id
+	}
+
+	if !req.canSeeUnpublished {
+		for _, status := range input.Statuses {
+			v.Check(status == data.MoviePublished, "status", "must be published")
+		}
+		input.Public = true
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		return nil, graphqlFailedValidationError(v.Errors)
+	}
+
+	if len(input.Genres) > 0 {
+		canonical, unknown, err := app.models.Genres.Canonicalize(input.Genres)
+		if err != nil {
+			return nil, app.graphqlServerError(req.c, err)
+		}
+		if len(unknown) > 0 {
+			v.AddError("genres", data.UnknownGenresMessage(unknown))
+			return nil, graphqlFailedValidationError(v.Errors)
+		}
+		input.Genres = canonical
+	}
+
+	movies, _, err := app.models.Movies.GetAll(input.MovieSearch, input.Filters)
+	if err != nil {
+		return nil, app.graphqlServerError(req.c, err)
+	}
+	return movies, nil
+}
+
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (interface{}, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	err := app.graphqlRequirePermission(c, data.PermissionMoviesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	user := app.contextGetUser(c)
+	movie := &data.Movie{
+		Title:     p.Args["title"].(string),
+		Year:      int32(p.Args["year"].(int)),
+		Runtime:   data.Runtime(p.Args["runtime"].(int)),
+		Genres:    graphqlStrings(p.Args["genres"]),
+		Status:    data.MovieDraft,
+		CreatedBy: &user.Id,
+		UpdatedBy: &user.Id,
+	}
+
+	v := validator.New()
+	err = app.canonicalizeGenres(v, movie)
+	if err != nil {
+		return nil, app.graphqlServerError(c, err)
+	}
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, graphqlFailedValidationError(v.Errors)
+	}
+
+	if !p.Args["force"].(bool) {
+		candidates, err := app.models.Movies.FindDuplicates(movie, app.config.duplicates.similarity)
+		if err != nil {
+			return nil, app.graphqlServerError(c, err)
+		}
+		if len(candidates) > 0 {
+			return nil, graphqlDuplicateMovieError(candidates)
+		}
+	}
+
+	err = app.models.Movies.Insert(movie)
+	if err != nil {
+		return nil, app.graphqlServerError(c, err)
+	}
+	return movie, nil
+}
+
+// readModifiableMovie fetches the movie a mutation changes once the request user is allowed to, like the
+// updateMovieHandler and deleteMovieHandler preamble
+func (app *application) readModifiableMovie(p graphql.ResolveParams) (*data.Movie, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	err := app.graphqlRequirePermission(c, data.PermissionMoviesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	movie, err := app.models.Movies.Get(graphqlID(p.Args["id"]))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFoundError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+
+	permissions, err := app.contextGetPermissions(c)
+	if err != nil {
+		return nil, app.graphqlServerError(c, err)
+	}
+	if !data.CanModifyMovie(app.contextGetUser(c), permissions, movie) {
+		return nil, graphqlNotPermittedError()
+	}
+
+	err = app.graphqlCheckVersion(p, movie.Version)
+	if err != nil {
+		return nil, err
+	}
+	return movie, nil
+}
+
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (interface{}, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	movie, err := app.readModifiableMovie(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if title, ok := p.Args["title"].(string); ok {
+		movie.Title = title
+	}
+	if year, ok := p.Args["year"].(int); ok {
+		movie.Year = int32(year)
+	}
+	if runtime, ok := p.Args["runtime"].(int); ok {
+		movie.Runtime = data.Runtime(runtime)
+	}
+	if genres := graphqlStrings(p.Args["genres"]); genres != nil {
+		movie.Genres = genres
+	}
+
+	v := validator.New()
+	err = app.canonicalizeGenres(v, movie)
+	if err != nil {
+		return nil, app.graphqlServerError(c, err)
+	}
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return nil, graphqlFailedValidationError(v.Errors)
+	}
+
+	movie.UpdatedBy = &app.contextGetUser(c).Id
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphqlEditConflictError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+	return movie, nil
+}
+
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (interface{}, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	movie, err := app.readModifiableMovie(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Movies.Delete(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphqlEditConflictError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+	return true, nil
+}
+
+func (app *application) resolveCreateReview(p graphql.ResolveParams) (interface{}, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	err := app.graphqlRequireAuthenticatedUser(c)
+	if err != nil {
+		return nil, err
+	}
+
+	movie, err := app.graphqlVisibleMovie(c, graphqlID(p.Args["movieId"]))
+	if err != nil {
+		return nil, err
+	}
+
+	user := app.contextGetUser(c)
+	review := &data.Review{
+		MovieId:  movie.Id,
+		UserId:   user.Id,
+		UserName: user.Name,
+		Rating:   int32(p.Args["rating"].(int)),
+		Body:     p.Args["body"].(string),
+	}
+
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		return nil, graphqlFailedValidationError(v.Errors)
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFoundError()
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("movie_id", "you have already reviewed this movie")
+			return nil, graphqlFailedValidationError(v.Errors)
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+	return review, nil
+}
+
+// readOwnReview fetches the review a mutation changes, only the author can change a review
+func (app *application) readOwnReview(p graphql.ResolveParams) (*data.Review, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	err := app.graphqlRequireAuthenticatedUser(c)
+	if err != nil {
+		return nil, err
+	}
+
+	movie, err := app.graphqlVisibleMovie(c, graphqlID(p.Args["movieId"]))
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := app.models.Reviews.Get(movie.Id, graphqlID(p.Args["id"]))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFoundError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+
+	if review.UserId != app.contextGetUser(c).Id {
+		return nil, graphqlNotPermittedError()
+	}
+
+	err = app.graphqlCheckVersion(p, review.Version)
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (app *application) resolveUpdateReview(p graphql.ResolveParams) (interface{}, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	review, err := app.readOwnReview(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if rating, ok := p.Args["rating"].(int); ok {
+		review.Rating = int32(rating)
+	}
+	if body, ok := p.Args["body"].(string); ok {
+		review.Body = body
+	}
+
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		return nil, graphqlFailedValidationError(v.Errors)
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphqlEditConflictError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+	return review, nil
+}
+
+func (app *application) resolveDeleteReview(p graphql.ResolveParams) (interface{}, error) {
+	c := graphqlRequestFrom(p.Context).c
+
+	review, err := app.readOwnReview(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Reviews.Delete(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphqlEditConflictError()
+		default:
+			return nil, app.graphqlServerError(c, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckGraphQLLimits(t *testing.T) {
+	app := &application{}
+	app.config.graphql.maxDepth = 4
+	app.config.graphql.maxComplexity = 400
+
+	schema, err := app.graphqlSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		wantErr   string
+	}{
+		{
+			name:  "shallow query",
+			query: `{ movie(id: 1) { title year } }`,
+		},
+		{
+			name:  "at the maximum depth",
+			query: `{ movie(id: 1) { reviews { author { name } } } }`,
+		},
+		{
+			name:    "over the maximum depth",
+			query:   `{ movie(id: 1) { reviews { movie { reviews { id } } } } }`,
+			wantErr: "deeper",
+		},
+		{
+			name:  "within the complexity",
+			query: `{ movies(pageSize: 10) { reviews(first: 10) { id } } }`,
+		},
+		{
+			name:    "over the complexity",
+			query:   `{ movies(pageSize: 50) { reviews(first: 100) { id } } }`,
+			wantErr: "complexity",
+		},
+		{
+			name:    "list sizes default when not given",
+			query:   `{ movies { reviews { author { name } } } }`,
+			wantErr: "complexity",
+		},
+		{
+			name: "aliases within the complexity",
+			query: `{
+				a: movie(id: 1) { title }
+				b: movie(id: 2) { title }
+			}`,
+		},
+		{
+			name: "aliases add up",
+			query: `{
+				a: movies(pageSize: 100) { title year }
+				b: movies(pageSize: 100) { title year }
+				c: movies(pageSize: 100) { title year }
+			}`,
+			wantErr: "complexity",
+		},
+		{
+			name:  "fragment within the depth",
+			query: `{ movie(id: 1) { ...details } } fragment details on Movie { reviews { id } }`,
+		},
+		{
+			name:    "fragment over the depth",
+			query:   `{ movie(id: 1) { ...details } } fragment details on Movie { reviews { movie { credits { id } } } }`,
+			wantErr: "deeper",
+		},
+		{
+			name:  "nested fragments count at their level",
+			query: `{ movie(id: 1) { ...reviews } } fragment reviews on Movie { reviews { ...author } } fragment author on Review { movie { title } author { name } }`,
+		},
+		{
+			name:    "inline fragment over the depth",
+			query:   `{ movie(id: 1) { ... on Movie { reviews { movie { reviews { id } } } } } }`,
+			wantErr: "deeper",
+		},
+		{
+			name:      "variable first within the complexity",
+			query:     `query ($first: Int) { movies(pageSize: 3) { reviews(first: $first) { id } } }`,
+			variables: map[string]interface{}{"first": float64(100)},
+		},
+		{
+			name:      "variable first over the complexity",
+			query:     `query ($first: Int) { movies(pageSize: 5) { reviews(first: $first) { id } } }`,
+			variables: map[string]interface{}{"first": float64(100)},
+			wantErr:   "complexity",
+		},
+		{
+			name:      "only the selected operation counts",
+			query:     `query small { movie(id: 1) { title } } query big { movies(pageSize: 100) { reviews(first: 100) { id } } }`,
+			operation: "small",
+		},
+		{
+			name:  "introspection within the limits",
+			query: `{ __typename __type(name: "Movie") { name fields { name } } }`,
+		},
+		{
+			name:    "introspection over the depth",
+			query:   `{ __schema { types { fields { type { ofType { name } } } } } }`,
+			wantErr: "deeper",
+		},
+		{
+			name:    "introspection over the complexity",
+			query:   `{ __schema { types { name description fields { name description } enumValues { name description } inputFields { name description } } } }`,
+			wantErr: "complexity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = app.checkGraphQLLimits(&schema, doc, tt.operation, tt.variables)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("err = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatchLoaderFetchesOncePerLevel(t *testing.T) {
+	type node struct {
+		Id int
+	}
+
+	var fetches [][]int
+	loader := newBatchLoader(func(ids []int) (map[int]*node, error) {
+		fetches = append(fetches, ids)
+		nodes := make(map[int]*node)
+		for _, id := range ids {
+			if id > 0 {
+				nodes[id] = &node{Id: id}
+			}
+		}
+		return nodes, nil
+	})
+
+	// the parent of a node is the node with half its id, several nodes share a parent
+	var nodeType *graphql.Object
+	nodeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Node",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.Int},
+				"parent": &graphql.Field{
+					Type: nodeType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loader.load(p.Source.(*node).Id / 2), nil
+					},
+				},
+			}
+		}),
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"nodes": &graphql.Field{
+					Type: graphql.NewList(nodeType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						var nodes []*node
+						for id := 2; id <= 9; id++ {
+							nodes = append(nodes, &node{Id: id})
+						}
+						return nodes, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ nodes { id parent { id parent { id } } } }`,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+
+	// the second level only fetches the parent not loaded by the first one
+	want := [][]int{{1, 2, 3, 4}, {0}}
+	if !reflect.DeepEqual(fetches, want) {
+		t.Errorf("fetches = %v, want %v", fetches, want)
+	}
+
+	nodes := result.Data.(map[string]interface{})["nodes"].([]interface{})
+	first := nodes[0].(map[string]interface{})
+	if parent := first["parent"].(map[string]interface{}); parent["id"] != 1 || parent["parent"] != nil {
+		t.Errorf("parent of node 2 = %v, want node 1 without parent", parent)
+	}
+}
+
+func TestBatchLoaderReportsFetchErrors(t *testing.T) {
+	fetches := 0
+	loader := newBatchLoader(func(ids []int) (map[int]string, error) {
+		fetches++
+		return nil, errors.New("fetch failed")
+	})
+
+	thunks := []func() (interface{}, error){loader.load(1), loader.load(2), loader.load(1)}
+	for _, thunk := range thunks {
+		if _, err := thunk(); err == nil || err.Error() != "fetch failed" {
+			t.Errorf("err = %v, want the fetch error", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %d times, want once", fetches)
+	}
+}
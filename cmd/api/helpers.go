@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
 	"github.com/duongbm/greenlight-gin/internal/validator"
 	"github.com/gin-gonic/gin"
+	"hash/fnv"
 	"io"
 	"net/http"
 	"net/url"
@@ -13,13 +16,18 @@ import (
 	"strings"
 )
 
+const maxBodyBytes = 1_048_576
+
 func (app *application) readJSON(c *gin.Context, dest interface{}) error {
 	// limit the size of request body to 1MB
 	var w = c.Writer
-	maxBytes := 1_048_576
-	c.Request.Body = http.MaxBytesReader(w, c.Request.Body, int64(maxBytes))
+	c.Request.Body = http.MaxBytesReader(w, c.Request.Body, int64(maxBodyBytes))
+
+	return app.decodeJSON(c.Request.Body, dest)
+}
 
-	dec := json.NewDecoder(c.Request.Body)
+func (app *application) decodeJSON(r io.Reader, dest interface{}) error {
+	dec := json.NewDecoder(r)
 	dec.DisallowUnknownFields() // disallow unknown fields in payload
 	err := dec.Decode(dest)
 	if err != nil {
@@ -42,7 +50,7 @@ func (app *application) readJSON(c *gin.Context, dest interface{}) error {
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
 			return fmt.Errorf("body contains unknown key %s", fieldName)
 		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+			return fmt.Errorf("body must not be larger than %d bytes", maxBodyBytes)
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
 		default:
@@ -87,6 +95,170 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return b
+}
+
+// includeFunc loads a related resource for each of the given record ids
+type includeFunc func(ids []int64) (map[int64]interface{}, error)
+
+// formatFunc rewrites the JSON value of a single field of a record
+type formatFunc func(value interface{}) (interface{}, error)
+
+func (app *application) readFields(qs url.Values, fieldSafeList, includeSafeList []string) data.Fields {
+	return data.Fields{
+		Fields:          app.readList(qs, "fields", []string{}),
+		FieldSafeList:   fieldSafeList,
+		Include:         app.readList(qs, "include", []string{}),
+		IncludeSafeList: includeSafeList,
+	}
+}
+
+// shapeJSON trims a record, or a slice of records, down to the requested fieldset,
+// rewrites fields with formatters and embeds the requested related resources using includes
+func (app *application) shapeJSON(value interface{}, fields data.Fields, includes map[string]includeFunc, formatters map[string]formatFunc) (interface{}, error) {
+	if len(fields.Fields) == 0 && len(fields.Include) == 0 && len(formatters) == 0 {
+		return value, nil
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var records []map[string]interface{}
+	single := len(js) > 0 && js[0] == '{'
+	if single {
+		var record map[string]interface{}
+		err = dec.Decode(&record)
+		records = append(records, record)
+	} else {
+		err = dec.Decode(&records)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields.Fields) > 0 {
+		for _, record := range records {
+			for key := range record {
+				if key != "id" && !validator.In(key, fields.Fields...) {
+					delete(record, key)
+				}
+			}
+		}
+	}
+
+	for key, format := range formatters {
+		for _, record := range records {
+			if fieldValue, ok := record[key]; ok {
+				record[key], err = format(fieldValue)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if len(fields.Include) > 0 {
+		ids := make([]int64, 0, len(records))
+		for _, record := range records {
+			id, err := record["id"].(json.Number).Int64()
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+
+		for _, name := range fields.Include {
+			related, err := includes[name](ids)
+			if err != nil {
+				return nil, err
+			}
+			for i, record := range records {
+				record[name] = related[ids[i]]
+			}
+		}
+	}
+
+	if single {
+		return records[0], nil
+	}
+	return records, nil
+}
+
+// etag builds a strong entity tag from a record id and its version
+func etag(id int64, version int32) string {
+	return fmt.Sprintf(`"%d-%d"`, id, version)
+}
+
+func matchETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfNoneMatch sets the ETag header and answers with 304 Not Modified
+// when the client already has the current representation. The query string shapes the representation so it
+// is part of a weak tag, which If-Match never matches. The records pulled in with include change without
+// the version changing, these responses are always sent in full and without a tag
+func (app *application) checkIfNoneMatch(c *gin.Context, etag string) bool {
+	query := c.Request.URL.Query()
+	if query.Has("include") {
+		return false
+	}
+	if len(query) > 0 {
+		hash := fnv.New64a()
+		hash.Write([]byte(query.Encode()))
+		etag = fmt.Sprintf(`W/"%s-%x"`, strings.Trim(etag, `"`), hash.Sum64())
+	}
+
+	c.Header("ETag", etag)
+	header := c.GetHeader("If-None-Match")
+	if header != "" && matchETag(header, strings.TrimPrefix(etag, "W/"), true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch answers with 412 Precondition Failed when the If-Match header does not match etag,
+// or with 428 Precondition Required when the header is missing and the server requires it
+func (app *application) checkIfMatch(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if app.config.requireIfMatch {
+			app.preconditionRequiredResponse(c)
+			return false
+		}
+		return true
+	}
+	if !matchETag(header, etag, false) {
+		app.preconditionFailedResponse(c)
+		return false
+	}
+	return true
+}
+
 func (app *application) background(fn func()) {
 	go func() {
 		defer func() {
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// readMovieList fetches the list addressed by the request, private lists of other users are reported as not found
+func (app *application) readMovieList(c *gin.Context) (*data.MovieList, bool) {
+	list, err := app.models.Lists.GetBySlug(c.Param("slug"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+
+	if !list.VisibleTo(app.contextGetUser(c)) {
+		app.notFoundResponse(c)
+		return nil, false
+	}
+	return list, true
+}
+
+// readOwnMovieList fetches the list addressed by the request as long as it belongs to the user and matches If-Match
+func (app *application) readOwnMovieList(c *gin.Context) (*data.MovieList, bool) {
+	list, ok := app.readMovieList(c)
+	if !ok {
+		return nil, false
+	}
+
+	// only the owner can edit a list
+	if list.UserId != app.contextGetUser(c).Id {
+		app.notPermittedResponse(c)
+		return nil, false
+	}
+
+	if !app.checkIfMatch(c, etag(list.Id, list.Version)) {
+		return nil, false
+	}
+	return list, true
+}
+
+func (app *application) createMovieListHandler(c *gin.Context) {
+	var input struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	list := &data.MovieList{
+		UserId:      app.contextGetUser(c).Id,
+		Title:       input.Title,
+		Description: input.Description,
+		Visibility:  input.Visibility,
+	}
+	if list.Visibility == "" {
+		list.Visibility = data.ListPrivate
+	}
+
+	v := validator.New()
+	if data.ValidateMovieList(v, list); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Insert(list)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(list.Id, list.Version))
+	c.JSON(http.StatusCreated, list)
+}
+
+func (app *application) showMovieListHandler(c *gin.Context) {
+	list, ok := app.readMovieList(c)
+	if !ok {
+		return
+	}
+
+	// the embedded movies change without the list version changing, like the responses with include the
+	// list is neither tagged nor revalidated
+	var err error
+	list.Items, err = app.models.Lists.GetItems(list.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	movieIds := make([]int64, len(list.Items))
+	for i, item := range list.Items {
+		movieIds[i] = item.MovieId
+	}
+	err = app.embedMovies(c, movieIds, func(i int, movie *data.Movie) { list.Items[i].Movie = movie })
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (app *application) updateMovieListHandler(c *gin.Context) {
+	list, ok := app.readOwnMovieList(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	if input.Title != nil {
+		list.Title = *input.Title
+	}
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+	if input.Visibility != nil {
+		list.Visibility = *input.Visibility
+	}
+
+	v := validator.New()
+	if data.ValidateMovieList(v, list); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Update(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(list.Id, list.Version))
+	c.JSON(http.StatusOK, list)
+}
+
+func (app *application) deleteMovieListHandler(c *gin.Context) {
+	list, ok := app.readOwnMovieList(c)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.Delete(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// listMovieListsHandler browses the public lists
+func (app *application) listMovieListsHandler(c *gin.Context) {
+	app.listMovieLists(c, 0)
+}
+
+// listOwnMovieListsHandler lists the lists of the authenticated user, private and unlisted ones included
+func (app *application) listOwnMovieListsHandler(c *gin.Context) {
+	app.listMovieLists(c, app.contextGetUser(c).Id)
+}
+
+func (app *application) listMovieLists(c *gin.Context, ownerId int64) {
+	var input struct {
+		Title string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Title = app.readString(qs, "title", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafeList = []string{"id", "title", "created_at", "-id", "-title", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	lists, metadata, err := app.models.Lists.GetAll(ownerId, input.Title, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     lists,
+	})
+}
+
+func (app *application) addMovieListItemHandler(c *gin.Context) {
+	list, ok := app.readOwnMovieList(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MovieId int64 `json:"movie_id"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.MovieId > 0, "movie_id", "must be provided")
+	v.Check(list.ItemCount < data.MaxListItems, "movie_id", fmt.Sprintf("a list must not contain more than %d movies", data.MaxListItems))
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	item := &data.MovieListItem{MovieId: input.MovieId}
+	item.Movie, err = app.getVisibleMovie(c, item.MovieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "must refer to an existing movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	err = app.models.Lists.AddItem(list, item)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateListItem):
+			v.AddError("movie_id", "this movie is already on the list")
+			app.failedValidationResponse(c, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(list.Id, list.Version))
+	c.JSON(http.StatusCreated, item)
+}
+
+func (app *application) removeMovieListItemHandler(c *gin.Context) {
+	list, ok := app.readOwnMovieList(c)
+	if !ok {
+		return
+	}
+
+	movieId := c.Param("movie_id")
+	_movieId, _ := strconv.ParseInt(movieId, 10, 64)
+
+	err := app.models.Lists.RemoveItem(list, _movieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(list.Id, list.Version))
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) reorderMovieListItemsHandler(c *gin.Context) {
+	list, ok := app.readOwnMovieList(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MovieIds []int64 `json:"movie_ids"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	list.Items, err = app.models.Lists.GetItems(list.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	// the new order must be a permutation of the movies currently on the list
+	onList := make(map[int64]bool, len(list.Items))
+	for _, item := range list.Items {
+		onList[item.MovieId] = true
+	}
+	v := validator.New()
+	v.Check(validator.Unique(input.MovieIds), "movie_ids", "must not contain duplicate movies")
+	v.Check(len(input.MovieIds) == len(list.Items), "movie_ids", "must contain every movie of the list")
+	for _, movieId := range input.MovieIds {
+		v.Check(onList[movieId], "movie_ids", fmt.Sprintf("movie %d is not on the list", movieId))
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Reorder(list, input.MovieIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	position := make(map[int64]int32, len(input.MovieIds))
+	for i, movieId := range input.MovieIds {
+		position[movieId] = int32(i + 1)
+	}
+	for _, item := range list.Items {
+		item.Position = position[item.MovieId]
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Position < list.Items[j].Position })
+
+	c.Header("ETag", etag(list.Id, list.Version))
+	c.JSON(http.StatusOK, list)
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/duongbm/greenlight-gin/internal/data"
 	"github.com/duongbm/greenlight-gin/internal/jsonlog"
 	"github.com/duongbm/greenlight-gin/internal/mailer"
+	"github.com/duongbm/greenlight-gin/internal/webhook"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"os"
@@ -18,9 +21,10 @@ const version = "1.0.0"
 
 // define config struct to hold all configuration settings for application
 type config struct {
-	port int
-	env  string
-	db   struct {
+	port           int
+	env            string
+	requireIfMatch bool
+	db             struct {
 		dsn         string
 		maxConn     int
 		MaxIdleConn int
@@ -33,14 +37,40 @@ type config struct {
 		password string
 		sender   string
 	}
+	lookup struct {
+		maxIds int
+	}
+	similarity data.SimilarityWeights
+	duplicates struct {
+		similarity float64
+	}
+	scheduler struct {
+		interval time.Duration
+	}
+	stream struct {
+		heartbeat time.Duration
+		buffer    int
+	}
+	webhooks struct {
+		interval    time.Duration
+		timeout     time.Duration
+		maxAttempts int
+		backoff     time.Duration
+	}
+	graphql struct {
+		maxDepth      int
+		maxComplexity int
+	}
 }
 
 // define an application struct to hold dependencies for HTTP handler, helper, middlewares, ...
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
+	config   config
+	logger   *jsonlog.Logger
+	models   data.Models
+	mailer   mailer.Mailer
+	stream   *movieStream
+	webhooks webhook.Sender
 }
 
 func main() {
@@ -49,11 +79,11 @@ func main() {
 	// Read value of port and env command-line flags into config struct
 	flag.IntVar(&cfg.port, "port", 8000, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "environment(development|staging|production)")
+	flag.BoolVar(&cfg.requireIfMatch, "require-if-match", false, "Reject PUT/PATCH/DELETE requests without an If-Match header")
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DB_DSN"), "PostgreSQL connection DSN")
 	flag.IntVar(&cfg.db.maxConn, "db-max-open-conns", 25, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.MaxIdleConn, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max idle timeout")
-	flag.Parse()
 
 	//SMTP config
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP server host")
@@ -62,9 +92,41 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "70ae820f1324af", "SMTP server password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.duongbm.net>", "SMTP sender")
 
+	flag.IntVar(&cfg.lookup.maxIds, "lookup-max-ids", 100, "Maximum number of movies resolved by a single lookup")
+
+	// similar movies score weights
+	flag.Float64Var(&cfg.similarity.Genres, "similarity-genres-weight", 0.5, "Weight of the genre overlap in the similar movies score")
+	flag.Float64Var(&cfg.similarity.Year, "similarity-year-weight", 0.15, "Weight of the year proximity in the similar movies score")
+	flag.Float64Var(&cfg.similarity.Runtime, "similarity-runtime-weight", 0.1, "Weight of the runtime proximity in the similar movies score")
+	flag.Float64Var(&cfg.similarity.CoRating, "similarity-co-rating-weight", 0.25, "Weight of the co-rating signal in the similar movies score")
+
+	flag.Float64Var(&cfg.duplicates.similarity, "duplicates-similarity", 0.6, "Trigram similarity from which a new movie title is reported as a duplicate")
+
+	flag.DurationVar(&cfg.scheduler.interval, "scheduler-interval", 30*time.Second, "How often scheduled movies are published and archived, 0 disables the scheduler")
+
+	flag.DurationVar(&cfg.stream.heartbeat, "stream-heartbeat", 15*time.Second, "Interval of the heartbeats sent to movie stream clients")
+	flag.IntVar(&cfg.stream.buffer, "stream-buffer", 256, "Changes a movie stream client may fall behind before being disconnected")
+
+	flag.DurationVar(&cfg.webhooks.interval, "webhook-interval", 5*time.Second, "How often due webhook deliveries are sent, 0 disables the deliveries")
+	flag.DurationVar(&cfg.webhooks.timeout, "webhook-timeout", 10*time.Second, "Timeout of a single webhook delivery attempt")
+	flag.IntVar(&cfg.webhooks.maxAttempts, "webhook-max-attempts", 8, "Attempts made before a webhook delivery is dead")
+	flag.DurationVar(&cfg.webhooks.backoff, "webhook-backoff", 30*time.Second, "Delay before the first retry of a webhook delivery, doubled on every retry")
+
+	flag.IntVar(&cfg.graphql.maxDepth, "graphql-max-depth", 8, "Maximum nesting depth of GraphQL queries")
+	flag.IntVar(&cfg.graphql.maxComplexity, "graphql-max-complexity", 5000, "Maximum complexity of GraphQL queries, fields below lists count once per item")
+	flag.Parse()
+
 	// Initialize a new logger which write messages to the standard out stream
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
+	// a heartbeat interval which isn't positive or a negative buffer would make the movie stream panic
+	if cfg.stream.heartbeat <= 0 {
+		logger.Fatal(errors.New("-stream-heartbeat must be positive"), nil)
+	}
+	if cfg.stream.buffer < 0 {
+		logger.Fatal(errors.New("-stream-buffer must not be negative"), nil)
+	}
+
 	// call openDB() to create then connection pool
 	db, err := openDB(cfg)
 	if err != nil {
@@ -75,14 +137,27 @@ func main() {
 
 	// Declare an instance of application struct, containing the config struct and logger
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:   cfg,
+		logger:   logger,
+		models:   data.NewModels(db),
+		mailer:   mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		stream:   newMovieStream(cfg.stream.buffer),
+		webhooks: webhook.New(cfg.webhooks.timeout),
 	}
 
-	err = app.serve()
-	// Start HTTP Server
+	// run a one-off command when one is given, otherwise start HTTP Server
+	switch flag.Arg(0) {
+	case "":
+		err = app.serve()
+	case "canonicalize-genres":
+		err = app.canonicalizeGenresCommand()
+	case "grant-permissions":
+		err = app.grantPermissionsCommand(flag.Args()[1:])
+	case "import":
+		err = app.importCommand(flag.Args()[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", flag.Arg(0))
+	}
 	if err != nil {
 		logger.Fatal(err, nil)
 	}
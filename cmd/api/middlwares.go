@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
 	"github.com/gin-gonic/gin"
+	"strings"
 )
 
 func (app *application) recoverPanic() gin.HandlerFunc {
@@ -16,3 +20,75 @@ func (app *application) recoverPanic() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func (app *application) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Authorization")
+
+		authorizationHeader := c.GetHeader("Authorization")
+		if authorizationHeader == "" {
+			app.contextSetUser(c, data.AnonymousUser)
+			c.Next()
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(c)
+			return
+		}
+
+		token := headerParts[1]
+		v := validator.New()
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(c)
+			return
+		}
+
+		user, err := app.models.User.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(c)
+			default:
+				app.serverErrorResponse(c, err)
+			}
+			return
+		}
+
+		app.contextSetUser(c, user)
+		c.Next()
+	}
+}
+
+func (app *application) requireAuthenticatedUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.contextGetUser(c)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (app *application) requirePermission(code string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := app.contextGetUser(c)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(c)
+			return
+		}
+
+		permissions, err := app.contextGetPermissions(c)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if !permissions.Include(code) {
+			app.notPermittedResponse(c)
+			return
+		}
+		c.Next()
+	}
+}
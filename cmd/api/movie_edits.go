@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// fieldChange is a field a suggested edit changes, along with its current and proposed values
+type fieldChange struct {
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+}
+
+// movieEditReview is a suggested edit as moderators see it, diffed against the current movie
+type movieEditReview struct {
+	*data.MovieEdit
+	Changes map[string]fieldChange `json:"changes"`
+	// Stale is set when the movie changed since the edit was suggested, such an edit can't be accepted anymore
+	Stale bool `json:"stale"`
+}
+
+// diffMovies returns the patchable fields which differ between the current and the proposed movie
+func diffMovies(current, proposed *data.Movie) (map[string]fieldChange, error) {
+	currentFields, err := movieDocumentFields(current)
+	if err != nil {
+		return nil, err
+	}
+	proposedFields, err := movieDocumentFields(proposed)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]fieldChange{}
+	for field, value := range proposedFields {
+		if !reflect.DeepEqual(currentFields[field], value) {
+			changes[field] = fieldChange{Current: currentFields[field], Proposed: value}
+		}
+	}
+	return changes, nil
+}
+
+// movieDocumentFields returns the patchable fields of the movie as they are written in JSON
+func movieDocumentFields(movie *data.Movie) (map[string]interface{}, error) {
+	js, err := json.Marshal(&movieDocument{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  movie.Genres,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(js, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// reviewMovieEdits diffs the edits against the current version of their movie
+func (app *application) reviewMovieEdits(edits []*data.MovieEdit) ([]*movieEditReview, error) {
+	var movieIds []int64
+	for _, edit := range edits {
+		movieIds = append(movieIds, edit.MovieId)
+	}
+	movies, err := app.models.Movies.GetByIds(movieIds)
+	if err != nil {
+		return nil, err
+	}
+	moviesById := make(map[int64]*data.Movie, len(movies))
+	for _, movie := range movies {
+		moviesById[movie.Id] = movie
+	}
+
+	reviews := []*movieEditReview{}
+	for _, edit := range edits {
+		movie, ok := moviesById[edit.MovieId]
+		if !ok {
+			continue
+		}
+		patched := *movie
+		err = app.applyMoviePatch(&patched, edit.Patch, true)
+		if err != nil {
+			return nil, err
+		}
+		changes, err := diffMovies(movie, &patched)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &movieEditReview{
+			MovieEdit: edit,
+			Changes:   changes,
+			Stale:     movie.Version != edit.BaseVersion,
+		})
+	}
+	return reviews, nil
+}
+
+// notifyMovieEditAuthor emails the author of the edit about the decision of the moderator
+func (app *application) notifyMovieEditAuthor(edit *data.MovieEdit, movie *data.Movie, templateFile string) {
+	app.background(func() {
+		user, err := app.models.User.Get(edit.UserId)
+		if err != nil {
+			app.logger.Error(err, nil)
+			return
+		}
+
+		err = app.mailer.Send(user.Email, templateFile, map[string]interface{}{
+			"userName":   user.Name,
+			"movieTitle": movie.Title,
+			"editId":     edit.Id,
+			"reason":     edit.Reason,
+		})
+		if err != nil {
+			app.logger.Error(err, nil)
+		}
+	})
+}
+
+func (app *application) createMovieEditHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	var input struct {
+		BaseVersion int32           `json:"base_version"`
+		Patch       json.RawMessage `json:"patch"`
+		Comment     string          `json:"comment"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	edit := &data.MovieEdit{
+		MovieId:     movie.Id,
+		UserId:      app.contextGetUser(c).Id,
+		BaseVersion: input.BaseVersion,
+		Patch:       input.Patch,
+		Comment:     input.Comment,
+	}
+
+	v := validator.New()
+	if data.ValidateMovieEdit(v, edit); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	if edit.BaseVersion != movie.Version {
+		app.conflictResponse(c, fmt.Errorf("the movie is at version %d, please suggest your edit against it", movie.Version))
+		return
+	}
+
+	// the patch must leave a valid movie, it is stored with canonical genres
+	patched := *movie
+	err = app.applyMoviePatch(&patched, edit.Patch, true)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+	err = app.canonicalizeGenres(v, &patched)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if data.ValidateMovie(v, &patched); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	changes, err := diffMovies(movie, &patched)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if len(changes) == 0 {
+		v.AddError("patch", "must change the movie")
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	proposed := make(map[string]interface{}, len(changes))
+	for field, change := range changes {
+		proposed[field] = change.Proposed
+	}
+	edit.Patch, err = json.Marshal(proposed)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	err = app.models.Edits.Insert(edit)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(edit.Id, edit.Version))
+	c.JSON(http.StatusCreated, &movieEditReview{MovieEdit: edit, Changes: changes})
+}
+
+func (app *application) listMovieEditsHandler(c *gin.Context) {
+	var input struct {
+		Status  string
+		MovieId int64
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Status = app.readString(qs, "status", data.EditPending)
+	input.MovieId = int64(app.readInt(qs, "movie_id", 0, v))
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "created_at", "-id", "-created_at"}
+
+	v.Check(validator.In(input.Status, data.EditStatuses...), "status", "must be pending, accepted or rejected")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	edits, metadata, err := app.models.Edits.GetAll(input.Status, input.MovieId, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	reviews, err := app.reviewMovieEdits(edits)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     reviews,
+	})
+}
+
+func (app *application) showMovieEditHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	edit, err := app.models.Edits.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	reviews, err := app.reviewMovieEdits([]*data.MovieEdit{edit})
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if len(reviews) == 0 {
+		app.notFoundResponse(c)
+		return
+	}
+
+	c.Header("ETag", etag(edit.Id, edit.Version))
+	c.JSON(http.StatusOK, reviews[0])
+}
+
+// readPendingMovieEdit fetches the edit a moderator decides on along with its movie
+func (app *application) readPendingMovieEdit(c *gin.Context) (*data.MovieEdit, *data.Movie, bool) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	edit, err := app.models.Edits.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, nil, false
+	}
+
+	if !app.checkIfMatch(c, etag(edit.Id, edit.Version)) {
+		return nil, nil, false
+	}
+	if edit.Status != data.EditPending {
+		app.conflictResponse(c, fmt.Errorf("the suggestion has already been %s", edit.Status))
+		return nil, nil, false
+	}
+
+	movie, err := app.models.Movies.Get(edit.MovieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, nil, false
+	}
+	return edit, movie, true
+}
+
+// acceptMovieEditHandler applies the suggested edit to the movie, the edit can only be accepted as long
+// as the movie is still at the version the edit was suggested against
+func (app *application) acceptMovieEditHandler(c *gin.Context) {
+	edit, movie, ok := app.readPendingMovieEdit(c)
+	if !ok {
+		return
+	}
+
+	err := app.applyMoviePatch(movie, edit.Patch, true)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	v := validator.New()
+	err = app.canonicalizeGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.AcceptEdit(edit, movie, app.contextGetUser(c).Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrMovieChanged):
+			app.conflictResponse(c, errors.New("the movie has changed since the suggestion was made, it can't be accepted anymore"))
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	app.notifyMovieEditAuthor(edit, movie, "movie_edit_accepted.tmpl")
+
+	c.Header("ETag", etag(edit.Id, edit.Version))
+	c.JSON(http.StatusOK, edit)
+}
+
+func (app *application) rejectMovieEditHandler(c *gin.Context) {
+	edit, movie, ok := app.readPendingMovieEdit(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(input.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Edits.Resolve(edit, data.EditRejected, app.contextGetUser(c).Id, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	app.notifyMovieEditAuthor(edit, movie, "movie_edit_rejected.tmpl")
+
+	c.Header("ETag", etag(edit.Id, edit.Version))
+	c.JSON(http.StatusOK, edit)
+}
@@ -1,14 +1,176 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/jsonpatch"
 	"github.com/duongbm/greenlight-gin/internal/validator"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
+var movieFieldSafeList = []string{"id", "title", "year", "runtime", "genres", "rating_average", "rating_count", "status",
+	"publish_at", "unpublish_at", "created_by", "updated_by", "version"}
+
+var movieSortSafeList = []string{"id", "title", "year", "runtime", "rating_average", "rating_count",
+	"-id", "-title", "-year", "-runtime", "-rating_average", "-rating_count"}
+
+// canSeeUnpublished reports whether the request user may see movies which aren't published yet or anymore
+func (app *application) canSeeUnpublished(c *gin.Context) (bool, error) {
+	permissions, err := app.contextGetPermissions(c)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include(data.PermissionMoviesWrite), nil
+}
+
+// authorizeMovieChange checks the request user may edit or delete the movie, see data.CanModifyMovie,
+// and sends a 403 response when they can't
+func (app *application) authorizeMovieChange(c *gin.Context, movie *data.Movie) bool {
+	permissions, err := app.contextGetPermissions(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return false
+	}
+	if !data.CanModifyMovie(app.contextGetUser(c), permissions, movie) {
+		app.notPermittedResponse(c)
+		return false
+	}
+	return true
+}
+
+// getVisibleMovie fetches the movie when the request user may see it, editors see every movie and
+// anyone else only the movies the public can see
+func (app *application) getVisibleMovie(c *gin.Context, id int64, columns ...string) (*data.Movie, error) {
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		return nil, err
+	}
+	if canSeeUnpublished {
+		return app.models.Movies.Get(id, columns...)
+	}
+	return app.models.Movies.GetPublic(id, columns...)
+}
+
+// readVisibleMovie fetches the movie addressed by the request when the request user may see it, writing the
+// error response when it can't, hidden movies are not found
+func (app *application) readVisibleMovie(c *gin.Context) (*data.Movie, bool) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return movie, true
+}
+
+// movieIncludes lists the related resources which can be embedded in movie responses with include=
+func (app *application) movieIncludes() map[string]includeFunc {
+	return map[string]includeFunc{
+		"credits": func(ids []int64) (map[int64]interface{}, error) {
+			credits, err := app.models.Credits.GetForMovies(ids)
+			if err != nil {
+				return nil, err
+			}
+			related := make(map[int64]interface{}, len(ids))
+			for _, id := range ids {
+				related[id] = credits[id]
+			}
+			return related, nil
+		},
+		"collections": func(ids []int64) (map[int64]interface{}, error) {
+			memberships, err := app.models.Collections.GetForMovies(ids)
+			if err != nil {
+				return nil, err
+			}
+			related := make(map[int64]interface{}, len(ids))
+			for _, id := range ids {
+				related[id] = memberships[id]
+			}
+			return related, nil
+		},
+		"external_ids": func(ids []int64) (map[int64]interface{}, error) {
+			externalIds, err := app.models.ExternalIds.GetForMovies(ids)
+			if err != nil {
+				return nil, err
+			}
+			related := make(map[int64]interface{}, len(ids))
+			for _, id := range ids {
+				related[id] = externalIds[id]
+			}
+			return related, nil
+		},
+	}
+}
+
+func (app *application) readMovieFields(c *gin.Context, v *validator.Validator) data.Fields {
+	var includeSafeList []string
+	for name := range app.movieIncludes() {
+		includeSafeList = append(includeSafeList, name)
+	}
+
+	fields := app.readFields(c.Request.URL.Query(), movieFieldSafeList, includeSafeList)
+	data.ValidateFields(v, fields)
+	return fields
+}
+
+// movieFormatters reads the per-request output formats of movie fields
+func (app *application) movieFormatters(c *gin.Context, v *validator.Validator) map[string]formatFunc {
+	format := app.readString(c.Request.URL.Query(), "runtime_format", data.RuntimeFormatMins)
+	v.Check(validator.In(format, data.RuntimeFormats...), "runtime_format", "invalid runtime format value")
+	if format == data.RuntimeFormatMins {
+		return nil
+	}
+
+	return map[string]formatFunc{
+		"runtime": func(value interface{}) (interface{}, error) {
+			js, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			var runtime data.Runtime
+			err = runtime.UnmarshalJSON(js)
+			if err != nil {
+				return nil, err
+			}
+			return runtime.Format(format), nil
+		},
+	}
+}
+
+// canonicalizeGenres rewrites the movie genres to canonical slugs, unknown genres are reported in v
+func (app *application) canonicalizeGenres(v *validator.Validator, movie *data.Movie) error {
+	if len(movie.Genres) == 0 {
+		return nil
+	}
+
+	canonical, unknown, err := app.models.Genres.Canonicalize(movie.Genres)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		v.AddError("genres", data.UnknownGenresMessage(unknown))
+		return nil
+	}
+
+	movie.Genres = canonical
+	return nil
+}
+
 func (app *application) createMovieHandler(c *gin.Context) {
 	var input struct {
 		Title   string       `json:"title"`
@@ -22,27 +184,67 @@ func (app *application) createMovieHandler(c *gin.Context) {
 		return
 	}
 
+	user := app.contextGetUser(c)
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:     input.Title,
+		Year:      input.Year,
+		Runtime:   input.Runtime,
+		Genres:    input.Genres,
+		Status:    data.MovieDraft,
+		CreatedBy: &user.Id,
+		UpdatedBy: &user.Id,
 	}
 
 	v := validator.New()
+	force := app.readBool(c.Request.URL.Query(), "force", false, v)
+	formatters := app.movieFormatters(c, v)
+	err = app.canonicalizeGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
 
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
 
+	if !force {
+		candidates, err := app.models.Movies.FindDuplicates(movie, app.config.duplicates.similarity)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if len(candidates) > 0 {
+			app.duplicateMovieResponse(c, candidates)
+			return
+		}
+	}
+
 	err = app.models.Movies.Insert(movie)
 	if err != nil {
 		app.serverErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, movie)
+	app.writeMovie(c, movie, formatters)
+}
+
+// redirectMergedMovie sends clients asking for a movie which was merged into another one to the survivor
+func (app *application) redirectMergedMovie(c *gin.Context, id int64) {
+	movieId, err := app.models.Movies.GetRedirect(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	location := url.URL{Path: fmt.Sprintf("/movies/%d", movieId), RawQuery: c.Request.URL.RawQuery}
+	c.Redirect(http.StatusMovedPermanently, location.String())
 }
 
 func (app *application) showMovieHandler(c *gin.Context) {
@@ -50,17 +252,58 @@ func (app *application) showMovieHandler(c *gin.Context) {
 
 	_id, _ := strconv.ParseInt(id, 10, 64)
 
-	movie, err := app.models.Movies.Get(_id)
+	app.showMovie(c, _id)
+}
+
+// showMovie writes the movie shaped by the fields, include and runtime_format parameters of the request
+func (app *application) showMovie(c *gin.Context, id int64) {
+	v := validator.New()
+	fields := app.readMovieFields(c, v)
+	formatters := app.movieFormatters(c, v)
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	// the version is always needed to compute the ETag
+	columns := fields.Columns()
+	if columns != nil && !validator.In("version", columns...) {
+		columns = append(columns, "version")
+	}
+
+	movie, err := app.getVisibleMovie(c, id, columns...)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(c)
+			app.redirectMergedMovie(c, id)
 		default:
 			app.serverErrorResponse(c, err)
 		}
 		return
 	}
-	c.JSON(http.StatusOK, movie)
+
+	if app.checkIfNoneMatch(c, etag(movie.Id, movie.Version)) {
+		return
+	}
+
+	response, err := app.shapeJSON(movie, fields, app.movieIncludes(), formatters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// writeMovie writes a changed movie with its ETag, formatted by the runtime_format parameter of the request
+func (app *application) writeMovie(c *gin.Context, movie *data.Movie, formatters map[string]formatFunc) {
+	response, err := app.shapeJSON(movie, data.Fields{}, nil, formatters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(movie.Id, movie.Version))
+	c.JSON(http.StatusOK, response)
 }
 
 func (app *application) updateMovieHandler(c *gin.Context) {
@@ -77,6 +320,14 @@ func (app *application) updateMovieHandler(c *gin.Context) {
 		return
 	}
 
+	if !app.authorizeMovieChange(c, movie) {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(movie.Id, movie.Version)) {
+		return
+	}
+
 	var input struct {
 		Title   string       `json:"title"`
 		Year    int32        `json:"year"`
@@ -96,23 +347,37 @@ func (app *application) updateMovieHandler(c *gin.Context) {
 	movie.Genres = input.Genres
 
 	v := validator.New()
+	formatters := app.movieFormatters(c, v)
+	err = app.canonicalizeGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
 	if data.ValidateMovie(v, movie); !v.Valid() {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
 
+	movie.UpdatedBy = &app.contextGetUser(c).Id
 	err = app.models.Movies.Update(movie)
 	if err != nil {
-		app.serverErrorResponse(c, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
 		return
 	}
-	c.JSON(http.StatusOK, movie)
+
+	app.writeMovie(c, movie, formatters)
 }
 
 func (app *application) deleteMovieHandler(c *gin.Context) {
 	id := c.Param("id")
 	_id, _ := strconv.ParseInt(id, 10, 64)
-	err := app.models.Movies.Delete(_id)
+	movie, err := app.models.Movies.Get(_id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -120,6 +385,26 @@ func (app *application) deleteMovieHandler(c *gin.Context) {
 		default:
 			app.serverErrorResponse(c, err)
 		}
+		return
+	}
+
+	if !app.authorizeMovieChange(c, movie) {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(movie.Id, movie.Version)) {
+		return
+	}
+
+	err = app.models.Movies.Delete(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
 	}
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -139,6 +424,60 @@ func (app *application) partialUpdateMovieHandler(c *gin.Context) {
 		return
 	}
 
+	if !app.authorizeMovieChange(c, movie) {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(movie.Id, movie.Version)) {
+		return
+	}
+
+	// dispatch on the patch format, plain JSON only sets the given fields
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json-patch+json":
+		err = app.readMoviePatch(c, movie)
+	default:
+		err = app.readMoviePartial(c, movie)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.conflictResponse(c, err)
+		default:
+			app.badRequestResponse(c, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	formatters := app.movieFormatters(c, v)
+	err = app.canonicalizeGenres(v, movie)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	movie.UpdatedBy = &app.contextGetUser(c).Id
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	app.writeMovie(c, movie, formatters)
+}
+
+func (app *application) readMoviePartial(c *gin.Context, movie *data.Movie) error {
 	var input struct {
 		Title   *string       `json:"title"`
 		Year    *int32        `json:"year"`
@@ -146,10 +485,9 @@ func (app *application) partialUpdateMovieHandler(c *gin.Context) {
 		Genres  []string      `json:"genres"`
 	}
 
-	err = app.readJSON(c, &input)
+	err := app.readJSON(c, &input)
 	if err != nil {
-		app.badRequestResponse(c, err)
-		return
+		return err
 	}
 
 	if input.Title != nil {
@@ -164,30 +502,67 @@ func (app *application) partialUpdateMovieHandler(c *gin.Context) {
 	if input.Genres != nil {
 		movie.Genres = input.Genres
 	}
+	return nil
+}
 
-	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(c, v.Errors)
-		return
+// movieDocument is the part of a movie which JSON Patch and JSON Merge Patch operate on
+type movieDocument struct {
+	Title   string       `json:"title"`
+	Year    int32        `json:"year"`
+	Runtime data.Runtime `json:"runtime"`
+	Genres  []string     `json:"genres"`
+}
+
+// readMoviePatch applies a RFC 7396 JSON Merge Patch or a RFC 6902 JSON Patch to the movie
+func (app *application) readMoviePatch(c *gin.Context, movie *data.Movie) error {
+	var patch json.RawMessage
+	err := app.readJSON(c, &patch)
+	if err != nil {
+		return err
 	}
 
-	err = app.models.Movies.Update(movie)
+	return app.applyMoviePatch(movie, patch, c.ContentType() == "application/merge-patch+json")
+}
+
+// applyMoviePatch applies patch to the movie, a RFC 7396 JSON Merge Patch when merge is set and
+// a RFC 6902 JSON Patch otherwise
+func (app *application) applyMoviePatch(movie *data.Movie, patch []byte, merge bool) error {
+	doc, err := json.Marshal(&movieDocument{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  movie.Genres,
+	})
 	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(c)
-		default:
-			app.serverErrorResponse(c, err)
-		}
-		return
+		return err
+	}
+
+	var patched []byte
+	if merge {
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	} else {
+		patched, err = jsonpatch.Apply(doc, patch)
+	}
+	if err != nil {
+		return err
+	}
+
+	var input movieDocument
+	err = app.decodeJSON(bytes.NewReader(patched), &input)
+	if err != nil {
+		return err
 	}
-	c.JSON(http.StatusOK, movie)
+
+	movie.Title = input.Title
+	movie.Year = input.Year
+	movie.Runtime = input.Runtime
+	movie.Genres = input.Genres
+	return nil
 }
 
 func (app *application) listMovieHandler(c *gin.Context) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieSearch
 		data.Filters
 	}
 
@@ -196,17 +571,70 @@ func (app *application) listMovieHandler(c *gin.Context) {
 	qs := c.Request.URL.Query()
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readList(qs, "genres", []string{})
+	input.PersonId = int64(app.readInt(qs, "person_id", 0, v))
+	input.CollectionId = int64(app.readInt(qs, "collection_id", 0, v))
+	input.HasExternal = app.readList(qs, "has_external", []string{})
+	input.MissingExternal = app.readList(qs, "missing_external", []string{})
+	input.Statuses = app.readList(qs, "status", []string{})
+	owner := app.readString(qs, "owner", "")
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafeList = movieSortSafeList
+	fields := app.readMovieFields(c, v)
+	formatters := app.movieFormatters(c, v)
+	data.ValidateMovieSearch(v, input.MovieSearch)
+
+	// owner=me lists the movies the request user created
+	v.Check(owner == "" || owner == "me", "owner", "must be me")
+	if owner == "me" {
+		user := app.contextGetUser(c)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(c)
+			return
+		}
+		input.CreatedBy = user.Id
+	}
+
+	// only editors can see movies which aren't published or are outside of their publishing window
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !canSeeUnpublished {
+		for _, status := range input.Statuses {
+			v.Check(status == data.MoviePublished, "status", "must be published")
+		}
+		input.Public = true
+	}
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(c, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	if len(input.Genres) > 0 {
+		canonical, unknown, err := app.models.Genres.Canonicalize(input.Genres)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if len(unknown) > 0 {
+			v.AddError("genres", data.UnknownGenresMessage(unknown))
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+		input.Genres = canonical
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieSearch, input.Filters, fields.Columns()...)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	response, err := app.shapeJSON(movies, fields, app.movieIncludes(), formatters)
 	if err != nil {
 		app.serverErrorResponse(c, err)
 		return
@@ -214,6 +642,337 @@ func (app *application) listMovieHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, map[string]interface{}{
 		"metadata": metadata,
-		"data":     movies,
+		"data":     response,
 	})
 }
+
+func (app *application) lookupMoviesHandler(c *gin.Context) {
+	var input struct {
+		Ids []int64 `json:"ids"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	v := validator.New()
+	fields := app.readMovieFields(c, v)
+	formatters := app.movieFormatters(c, v)
+	v.Check(len(input.Ids) > 0, "ids", "must be provided")
+	v.Check(len(input.Ids) <= app.config.lookup.maxIds, "ids", fmt.Sprintf("must not contain more than %d ids", app.config.lookup.maxIds))
+	v.Check(validator.Unique(input.Ids), "ids", "must not contain duplicate ids")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	columns := fields.Columns()
+	for _, column := range []string{"status", "publish_at", "unpublish_at"} {
+		if columns != nil && !validator.In(column, columns...) {
+			columns = append(columns, column)
+		}
+	}
+
+	movies, err := app.models.Movies.GetByIds(input.Ids, columns...)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	// movies the public can't see are reported as not found to anyone but editors
+	now := time.Now()
+	moviesById := make(map[int64]*data.Movie, len(movies))
+	for _, movie := range movies {
+		if movie.IsPublic(now) || canSeeUnpublished {
+			moviesById[movie.Id] = movie
+		}
+	}
+
+	// answer in the requested order
+	found := []*data.Movie{}
+	notFound := []int64{}
+	for _, id := range input.Ids {
+		if movie, ok := moviesById[id]; ok {
+			found = append(found, movie)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+
+	response, err := app.shapeJSON(found, fields, app.movieIncludes(), formatters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data":      response,
+		"not_found": notFound,
+	})
+}
+
+func (app *application) suggestMovieHandler(c *gin.Context) {
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	prefix := strings.TrimSpace(app.readString(qs, "prefix", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(prefix != "", "prefix", "must be provided")
+	v.Check(len(prefix) <= 100, "prefix", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Suggest(prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data": suggestions,
+	})
+}
+
+func (app *application) similarMoviesHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	_, err := app.getVisibleMovie(c, _id, "id")
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	movies, err := app.models.Movies.Similar(_id, app.config.similarity, limit)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data": movies,
+	})
+}
+
+func (app *application) mergeMovieHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	survivor, err := app.models.Movies.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	// merging changes the survivor and deletes the duplicate, so the request user must be allowed to modify both
+	if !app.authorizeMovieChange(c, survivor) {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(survivor.Id, survivor.Version)) {
+		return
+	}
+
+	var input struct {
+		DuplicateId int64 `json:"duplicate_id"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.DuplicateId > 0, "duplicate_id", "must be provided")
+	v.Check(input.DuplicateId != survivor.Id, "duplicate_id", "must not be the movie itself")
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	duplicate, err := app.models.Movies.Get(input.DuplicateId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("duplicate_id", "must refer to an existing movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	if !app.authorizeMovieChange(c, duplicate) {
+		return
+	}
+
+	err = app.models.Movies.Merge(survivor, duplicate)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound), errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	// reviews moved over, reload the rating aggregate
+	survivor, err = app.models.Movies.Get(survivor.Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(survivor.Id, survivor.Version))
+	c.JSON(http.StatusOK, survivor)
+}
+
+// transitionMovieHandler moves the movie to status, the editorial state machine decides which transitions
+// are allowed and which permission each of them needs
+func (app *application) transitionMovieHandler(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		_id, _ := strconv.ParseInt(id, 10, 64)
+
+		movie, err := app.models.Movies.Get(_id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(c)
+			default:
+				app.serverErrorResponse(c, err)
+			}
+			return
+		}
+
+		if !app.checkIfMatch(c, etag(movie.Id, movie.Version)) {
+			return
+		}
+
+		permission, err := data.TransitionPermission(movie.Status, status)
+		if err != nil {
+			app.conflictResponse(c, fmt.Errorf("a %s movie can't be moved to %s", movie.Status, status))
+			return
+		}
+
+		permissions, err := app.contextGetPermissions(c)
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+		if !permissions.Include(permission) {
+			app.notPermittedResponse(c)
+			return
+		}
+
+		v := validator.New()
+		formatters := app.movieFormatters(c, v)
+		if !v.Valid() {
+			app.failedValidationResponse(c, v.Errors)
+			return
+		}
+
+		err = app.models.Movies.Transition(movie, status)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(c)
+			default:
+				app.serverErrorResponse(c, err)
+			}
+			return
+		}
+
+		app.writeMovie(c, movie, formatters)
+	}
+}
+
+// scheduleMovieHandler sets the publishing window of the movie, the scheduler publishes the movie once
+// publish_at has come if it is in review by then and archives it once unpublish_at has passed
+func (app *application) scheduleMovieHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	movie, err := app.models.Movies.Get(_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(movie.Id, movie.Version)) {
+		return
+	}
+
+	var input struct {
+		PublishAt   *time.Time `json:"publish_at"`
+		UnpublishAt *time.Time `json:"unpublish_at"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	movie.PublishAt = input.PublishAt
+	movie.UnpublishAt = input.UnpublishAt
+
+	v := validator.New()
+	formatters := app.movieFormatters(c, v)
+	if data.ValidateMovieSchedule(v, movie); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.Schedule(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	app.writeMovie(c, movie, formatters)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// readReview fetches the review addressed by the request, writing the error response when it can't
+func (app *application) readReview(c *gin.Context) (*data.Review, bool) {
+	movie, ok := app.readVisibleMovie(c)
+	if !ok {
+		return nil, false
+	}
+	reviewId := c.Param("review_id")
+	_reviewId, _ := strconv.ParseInt(reviewId, 10, 64)
+
+	review, err := app.models.Reviews.Get(movie.Id, _reviewId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return review, true
+}
+
+func (app *application) createReviewHandler(c *gin.Context) {
+	movie, ok := app.readVisibleMovie(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Rating int32  `json:"rating"`
+		Body   string `json:"body"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	user := app.contextGetUser(c)
+	review := &data.Review{
+		MovieId:  movie.Id,
+		UserId:   user.Id,
+		UserName: user.Name,
+		Rating:   input.Rating,
+		Body:     input.Body,
+	}
+
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("movie_id", "you have already reviewed this movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(review.Id, review.Version))
+	c.JSON(http.StatusCreated, review)
+}
+
+func (app *application) showReviewHandler(c *gin.Context) {
+	review, ok := app.readReview(c)
+	if !ok {
+		return
+	}
+
+	if app.checkIfNoneMatch(c, etag(review.Id, review.Version)) {
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+func (app *application) updateReviewHandler(c *gin.Context) {
+	review, ok := app.readReview(c)
+	if !ok {
+		return
+	}
+
+	// only the author can edit a review
+	if review.UserId != app.contextGetUser(c).Id {
+		app.notPermittedResponse(c)
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(review.Id, review.Version)) {
+		return
+	}
+
+	var input struct {
+		Rating *int32  `json:"rating"`
+		Body   *string `json:"body"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	if input.Rating != nil {
+		review.Rating = *input.Rating
+	}
+	if input.Body != nil {
+		review.Body = *input.Body
+	}
+
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(review.Id, review.Version))
+	c.JSON(http.StatusOK, review)
+}
+
+func (app *application) deleteReviewHandler(c *gin.Context) {
+	review, ok := app.readReview(c)
+	if !ok {
+		return
+	}
+
+	if review.UserId != app.contextGetUser(c).Id {
+		app.notPermittedResponse(c)
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(review.Id, review.Version)) {
+		return
+	}
+
+	err := app.models.Reviews.Delete(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) listReviewsHandler(c *gin.Context) {
+	movie, ok := app.readVisibleMovie(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafeList = []string{"id", "rating", "created_at", "-id", "-rating", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForMovie(movie.Id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     reviews,
+	})
+}
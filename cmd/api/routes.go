@@ -1,12 +1,16 @@
 package main
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/gin-gonic/gin"
+)
 
 func (app *application) routes() *gin.Engine {
 	router := gin.Default()
 
 	// attach middleware
 	router.Use(app.recoverPanic())
+	router.Use(app.authenticate())
 
 	router.NoRoute(app.notFoundResponse)
 	router.NoMethod(app.methodNotAllowedResponse)
@@ -16,12 +20,109 @@ func (app *application) routes() *gin.Engine {
 
 	// movies handler
 	router.GET("/movies", app.listMovieHandler)
+	router.GET("/movies/suggest", app.suggestMovieHandler)
+	router.GET("/movies/changes", app.listMovieChangesHandler)
+	router.GET("/movies/stream", app.streamMoviesHandler)
 	router.GET("/movies/:id", app.showMovieHandler)
-	router.PUT("/movies/:id", app.updateMovieHandler)
-	router.PATCH("/movies/:id", app.partialUpdateMovieHandler)
-	router.DELETE("/movies/:id", app.deleteMovieHandler)
-	router.POST("/movies", app.createMovieHandler)
+	router.GET("/movies/:id/similar", app.similarMoviesHandler)
+	router.PUT("/movies/:id", app.requirePermission("movies:write"), app.updateMovieHandler)
+	router.PATCH("/movies/:id", app.requirePermission("movies:write"), app.partialUpdateMovieHandler)
+	router.DELETE("/movies/:id", app.requirePermission("movies:write"), app.deleteMovieHandler)
+	router.POST("/movies", app.requirePermission("movies:write"), app.createMovieHandler)
+	router.POST("/movies/lookup", app.lookupMoviesHandler)
+	router.POST("/movies/:id/merge", app.requirePermission("movies:write"), app.mergeMovieHandler)
+
+	// editorial workflow handler
+	router.POST("/movies/:id/submit", app.requirePermission("movies:write"), app.transitionMovieHandler(data.MovieInReview))
+	router.POST("/movies/:id/publish", app.requirePermission("movies:write"), app.transitionMovieHandler(data.MoviePublished))
+	router.POST("/movies/:id/archive", app.requirePermission("movies:write"), app.transitionMovieHandler(data.MovieArchived))
+	router.POST("/movies/:id/retract", app.requirePermission("movies:write"), app.transitionMovieHandler(data.MovieDraft))
+	router.PUT("/movies/:id/schedule", app.requirePermission("movies:publish"), app.scheduleMovieHandler)
+
+	// suggested edits handler
+	router.POST("/movies/:id/suggestions", app.requireAuthenticatedUser(), app.createMovieEditHandler)
+	router.GET("/suggestions", app.requirePermission("movies:write"), app.listMovieEditsHandler)
+	router.GET("/suggestions/:id", app.requirePermission("movies:write"), app.showMovieEditHandler)
+	router.POST("/suggestions/:id/accept", app.requirePermission("movies:write"), app.acceptMovieEditHandler)
+	router.POST("/suggestions/:id/reject", app.requirePermission("movies:write"), app.rejectMovieEditHandler)
+
+	// graphql handler
+	router.POST("/graphql", app.graphqlHandler())
+
+	// webhooks handler
+	router.GET("/webhooks", app.requirePermission("webhooks:write"), app.listWebhooksHandler)
+	router.POST("/webhooks", app.requirePermission("webhooks:write"), app.createWebhookHandler)
+	router.GET("/webhooks/:id", app.requirePermission("webhooks:write"), app.showWebhookHandler)
+	router.PATCH("/webhooks/:id", app.requirePermission("webhooks:write"), app.updateWebhookHandler)
+	router.DELETE("/webhooks/:id", app.requirePermission("webhooks:write"), app.deleteWebhookHandler)
+	router.GET("/webhooks/:id/deliveries", app.requirePermission("webhooks:write"), app.listWebhookDeliveriesHandler)
+	router.GET("/webhooks/:id/deliveries/:delivery_id", app.requirePermission("webhooks:write"), app.showWebhookDeliveryHandler)
+	router.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", app.requirePermission("webhooks:write"), app.redeliverWebhookHandler)
+
+	// credits handler
+	router.GET("/movies/:id/credits", app.listMovieCreditsHandler)
+	router.POST("/movies/:id/credits", app.requirePermission("movies:write"), app.createMovieCreditHandler)
+	router.DELETE("/movies/:id/credits/:credit_id", app.requirePermission("movies:write"), app.deleteMovieCreditHandler)
+
+	// external ids handler
+	router.GET("/movies/by-external/:source/:id", app.showMovieByExternalIdHandler)
+	router.GET("/movies/:id/external-ids", app.listMovieExternalIdsHandler)
+	router.PUT("/movies/:id/external-ids/:source", app.requirePermission("movies:write"), app.setMovieExternalIdHandler)
+	router.DELETE("/movies/:id/external-ids/:source", app.requirePermission("movies:write"), app.deleteMovieExternalIdHandler)
+
+	// reviews handler
+	router.GET("/movies/:id/reviews", app.listReviewsHandler)
+	router.GET("/movies/:id/reviews/:review_id", app.showReviewHandler)
+	router.PATCH("/movies/:id/reviews/:review_id", app.requireAuthenticatedUser(), app.updateReviewHandler)
+	router.DELETE("/movies/:id/reviews/:review_id", app.requireAuthenticatedUser(), app.deleteReviewHandler)
+	router.POST("/movies/:id/reviews", app.requireAuthenticatedUser(), app.createReviewHandler)
+
+	// people handler
+	router.GET("/people", app.listPeopleHandler)
+	router.GET("/people/:id", app.showPersonHandler)
+	router.PUT("/people/:id", app.requirePermission("movies:write"), app.updatePersonHandler)
+	router.PATCH("/people/:id", app.requirePermission("movies:write"), app.partialUpdatePersonHandler)
+	router.DELETE("/people/:id", app.requirePermission("movies:write"), app.deletePersonHandler)
+	router.POST("/people", app.requirePermission("movies:write"), app.createPersonHandler)
+
+	// genres handler
+	router.GET("/genres", app.listGenresHandler)
+	router.GET("/genres/:id", app.showGenreHandler)
+	router.PUT("/genres/:id", app.requirePermission("movies:admin"), app.updateGenreHandler)
+	router.DELETE("/genres/:id", app.requirePermission("movies:admin"), app.deleteGenreHandler)
+	router.POST("/genres", app.requirePermission("movies:admin"), app.createGenreHandler)
+
+	// watchlist handler
+	router.GET("/users/me/watchlist", app.requireAuthenticatedUser(), app.listWatchlistHandler)
+	router.PATCH("/users/me/watchlist/:movie_id", app.requireAuthenticatedUser(), app.updateWatchlistItemHandler)
+	router.DELETE("/users/me/watchlist/:movie_id", app.requireAuthenticatedUser(), app.removeFromWatchlistHandler)
+	router.POST("/users/me/watchlist", app.requireAuthenticatedUser(), app.addToWatchlistHandler)
+
+	// watched history handler
+	router.GET("/users/me/watched", app.requireAuthenticatedUser(), app.listWatchedHandler)
+	router.DELETE("/users/me/watched/:id", app.requireAuthenticatedUser(), app.deleteWatchedHandler)
+	router.POST("/users/me/watched", app.requireAuthenticatedUser(), app.markWatchedHandler)
+
+	// collections handler
+	router.GET("/collections", app.listCollectionsHandler)
+	router.GET("/collections/:id", app.showCollectionHandler)
+	router.PUT("/collections/:id", app.requirePermission("movies:write"), app.updateCollectionHandler)
+	router.DELETE("/collections/:id", app.requirePermission("movies:write"), app.deleteCollectionHandler)
+	router.POST("/collections", app.requirePermission("movies:write"), app.createCollectionHandler)
+	router.PUT("/collections/:id/movies", app.requirePermission("movies:write"), app.setCollectionMoviesHandler)
+
+	// lists handler
+	router.GET("/lists", app.listMovieListsHandler)
+	router.GET("/lists/:slug", app.showMovieListHandler)
+	router.PATCH("/lists/:slug", app.requireAuthenticatedUser(), app.updateMovieListHandler)
+	router.DELETE("/lists/:slug", app.requireAuthenticatedUser(), app.deleteMovieListHandler)
+	router.POST("/lists", app.requireAuthenticatedUser(), app.createMovieListHandler)
+	router.GET("/users/me/lists", app.requireAuthenticatedUser(), app.listOwnMovieListsHandler)
+	router.PUT("/lists/:slug/items", app.requireAuthenticatedUser(), app.reorderMovieListItemsHandler)
+	router.DELETE("/lists/:slug/items/:movie_id", app.requireAuthenticatedUser(), app.removeMovieListItemHandler)
+	router.POST("/lists/:slug/items", app.requireAuthenticatedUser(), app.addMovieListItemHandler)
 
 	router.POST("/users", app.registerUserHandler)
+	router.POST("/tokens/authentication", app.createAuthenticationTokenHandler)
 	return router
 }
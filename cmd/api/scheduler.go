@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// runScheduler applies the publishing schedule of the movies every interval until ctx is done. Every API
+// instance runs the scheduler but the advisory lock taken by ApplySchedule lets only one of them work at a time,
+// a zero interval disables the scheduler
+func (app *application) runScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := app.models.Movies.ApplySchedule(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					app.logger.Error(err, nil)
+				}
+				continue
+			}
+			if result.Published > 0 || result.Archived > 0 {
+				app.logger.Info("applied movie schedule", map[string]string{
+					"published": strconv.Itoa(result.Published),
+					"archived":  strconv.Itoa(result.Archived),
+				})
+			}
+		}
+	}
+}
@@ -22,6 +22,21 @@ func (app *application) serve() error {
 
 	shutdownError := make(chan error)
 
+	// the scheduler and the movie stream stop along with the server, streaming clients are disconnected
+	// as soon as the shutdown starts since their requests would never end
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	app.background(func() {
+		app.runScheduler(workersCtx, app.config.scheduler.interval)
+	})
+	app.background(func() {
+		app.runMovieStream(workersCtx)
+	})
+	app.background(func() {
+		app.runWebhookDeliveries(workersCtx, app.config.webhooks.interval)
+	})
+	srv.RegisterOnShutdown(app.stream.close)
+
 	go func() {
 		// create a quit channel which carries os.Signal values
 		quit := make(chan os.Signal, 1)
@@ -29,6 +44,7 @@ func (app *application) serve() error {
 		s := <-quit
 
 		app.logger.Info("caught signal", map[string]string{"signal": s.String()})
+		stopWorkers()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	pq "github.com/lib/pq"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamPageSize is the number of changes read from the feed at once
+const streamPageSize = 500
+
+// streamMaxBackoff caps the delay between two attempts to start the movie stream
+const streamMaxBackoff = time.Minute
+
+// movieStream fans the change feed out to the SSE clients of this API instance. A LISTEN connection learns
+// about changes committed by any instance, the stream then reads them from the feed and hands them to every
+// subscriber. A subscriber which falls more than its buffer behind is dropped, its client reconnects with
+// Last-Event-ID and catches up from the feed
+type movieStream struct {
+	mu          sync.Mutex
+	buffer      int
+	subscribers map[chan *data.MovieChange]struct{}
+	closed      bool
+}
+
+func newMovieStream(buffer int) *movieStream {
+	return &movieStream{
+		buffer:      buffer,
+		subscribers: make(map[chan *data.MovieChange]struct{}),
+	}
+}
+
+// subscribe returns a channel of the changes to come, it is closed when the subscriber is dropped or the stream
+// is closed. ok is false once the stream is closed
+func (s *movieStream) subscribe() (changes chan *data.MovieChange, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, false
+	}
+	changes = make(chan *data.MovieChange, s.buffer)
+	s.subscribers[changes] = struct{}{}
+	return changes, true
+}
+
+func (s *movieStream) unsubscribe(changes chan *data.MovieChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[changes]; ok {
+		delete(s.subscribers, changes)
+		close(changes)
+	}
+}
+
+func (s *movieStream) publish(changes []*data.MovieChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for subscriber := range s.subscribers {
+	send:
+		for _, change := range changes {
+			select {
+			case subscriber <- change:
+			default:
+				delete(s.subscribers, subscriber)
+				close(subscriber)
+				break send
+			}
+		}
+	}
+}
+
+// close ends every subscription so the streaming handlers return and the server can shut down
+func (s *movieStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for subscriber := range s.subscribers {
+		delete(s.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// runMovieStream listens for new changes until ctx is done and publishes them to the stream. Failing to read the
+// feed or to start listening is retried with an exponential backoff
+func (app *application) runMovieStream(ctx context.Context) {
+	var seq int64
+	ok := app.retryMovieStream(ctx, func() error {
+		var err error
+		seq, err = app.models.Changes.LastSeq()
+		return err
+	})
+	if !ok {
+		return
+	}
+
+	listener := pq.NewListener(app.config.db.dsn, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.Error(err, nil)
+		}
+	})
+	defer listener.Close()
+	// Listen waits for the listener to reconnect when the database is down, closing it stops the wait
+	stop := context.AfterFunc(ctx, func() {
+		listener.Close()
+	})
+	defer stop()
+
+	ok = app.retryMovieStream(ctx, func() error {
+		return listener.Listen(data.MovieChangesChannel)
+	})
+	if !ok {
+		return
+	}
+
+	for {
+		// a nil notification means the connection was re-established and notifications may have been missed,
+		// the feed is read after every notification and every ping anyway
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+
+		for {
+			changes, err := app.models.Changes.GetAll(seq, streamPageSize)
+			if err != nil {
+				app.logger.Error(err, nil)
+				break
+			}
+			if len(changes) > 0 {
+				app.stream.publish(changes)
+				seq = changes[len(changes)-1].Seq
+			}
+			if len(changes) < streamPageSize {
+				break
+			}
+		}
+	}
+}
+
+// retryMovieStream calls fn until it succeeds, waiting twice as long after every failure up to
+// streamMaxBackoff. It returns false when ctx is done first
+func (app *application) retryMovieStream(ctx context.Context, fn func() error) bool {
+	backoff := time.Second
+	for {
+		err := fn()
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		app.logger.Error(err, nil)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, streamMaxBackoff)
+	}
+}
+
+// streamMoviesHandler pushes the movie changes as Server-Sent Events, the id of each event is a change token.
+// Clients which reconnect with Last-Event-ID, or pass since, first get the changes they missed
+func (app *application) streamMoviesHandler(c *gin.Context) {
+	since := c.GetHeader("Last-Event-ID")
+	if since == "" {
+		since = c.Query("since")
+	}
+
+	var seq int64
+	if since != "" {
+		var err error
+		seq, err = decodeChangeToken(since)
+		if err != nil {
+			app.failedValidationResponse(c, map[string]string{"since": "must be a token returned by a previous call"})
+			return
+		}
+	}
+
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	// subscribing before catching up means no change falls in between
+	changes, ok := app.stream.subscribe()
+	if !ok {
+		app.shuttingDownResponse(c)
+		return
+	}
+	defer app.stream.unsubscribe(changes)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// the server write timeout would cut the stream, each write gets its own deadline instead
+	rc := http.NewResponseController(c.Writer)
+	write := func(fn func() error) error {
+		err := rc.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err != nil {
+			return err
+		}
+		err = fn()
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	send := func(change *data.MovieChange) error {
+		if !canSeeUnpublished {
+			change = change.ForPublic()
+		}
+		return write(func() error {
+			return sse.Encode(c.Writer, sse.Event{Id: encodeChangeToken(change.Seq), Event: change.Kind, Data: change})
+		})
+	}
+
+	err = write(func() error {
+		_, err := fmt.Fprint(c.Writer, ": connected\n\n")
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	if since != "" {
+		for {
+			missed, err := app.models.Changes.GetAll(seq, streamPageSize)
+			if err != nil {
+				app.logError(c, err)
+				return
+			}
+			for _, change := range missed {
+				if send(change) != nil {
+					return
+				}
+				seq = change.Seq
+			}
+			if len(missed) < streamPageSize {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			// the changes read while catching up may come again
+			if change.Seq <= seq {
+				continue
+			}
+			if send(change) != nil {
+				return
+			}
+			seq = change.Seq
+		case <-heartbeat.C:
+			err := write(func() error {
+				_, err := fmt.Fprint(c.Writer, ": heartbeat\n\n")
+				return err
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
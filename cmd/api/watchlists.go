@@ -0,0 +1,368 @@
+package main
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// canonicalizeSearchGenres maps the genres filter of search onto canonical slugs, unknown genres are reported in v
+func (app *application) canonicalizeSearchGenres(v *validator.Validator, search *data.MovieSearch) error {
+	if len(search.Genres) == 0 {
+		return nil
+	}
+
+	canonical, unknown, err := app.models.Genres.Canonicalize(search.Genres)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		v.AddError("genres", data.UnknownGenresMessage(unknown))
+		return nil
+	}
+	search.Genres = canonical
+	return nil
+}
+
+// embedMovies loads the movie of every list entry in a single query, the movies the request user can't see
+// are left out
+func (app *application) embedMovies(c *gin.Context, movieIds []int64, embed func(i int, movie *data.Movie)) error {
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		return err
+	}
+	movies, err := app.models.Movies.GetByIds(movieIds)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	moviesById := make(map[int64]*data.Movie, len(movies))
+	for _, movie := range movies {
+		if canSeeUnpublished || movie.IsPublic(now) {
+			moviesById[movie.Id] = movie
+		}
+	}
+	for i, id := range movieIds {
+		embed(i, moviesById[id])
+	}
+	return nil
+}
+
+func (app *application) addToWatchlistHandler(c *gin.Context) {
+	var input struct {
+		MovieId  int64  `json:"movie_id"`
+		Priority int32  `json:"priority"`
+		Notes    string `json:"notes"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	item := &data.WatchlistItem{
+		UserId:   app.contextGetUser(c).Id,
+		MovieId:  input.MovieId,
+		Priority: input.Priority,
+		Notes:    input.Notes,
+	}
+
+	v := validator.New()
+	if data.ValidateWatchlistItem(v, item); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	item.Movie, err = app.getVisibleMovie(c, item.MovieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "must refer to an existing movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	err = app.models.Watchlist.Insert(item)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateWatchlistItem):
+			v.AddError("movie_id", "this movie is already on your watchlist")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func (app *application) updateWatchlistItemHandler(c *gin.Context) {
+	movieId := c.Param("movie_id")
+	_movieId, _ := strconv.ParseInt(movieId, 10, 64)
+
+	item, err := app.models.Watchlist.Get(app.contextGetUser(c).Id, _movieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	var input struct {
+		Position *int32  `json:"position"`
+		Priority *int32  `json:"priority"`
+		Notes    *string `json:"notes"`
+	}
+	err = app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	position := item.Position
+	if input.Position != nil {
+		position = *input.Position
+	}
+	if input.Priority != nil {
+		item.Priority = *input.Priority
+	}
+	if input.Notes != nil {
+		item.Notes = *input.Notes
+	}
+
+	v := validator.New()
+	v.Check(position > 0, "position", "must be greater than zero")
+	if data.ValidateWatchlistItem(v, item); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlist.Update(item, position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+func (app *application) removeFromWatchlistHandler(c *gin.Context) {
+	movieId := c.Param("movie_id")
+	_movieId, _ := strconv.ParseInt(movieId, 10, 64)
+
+	err := app.models.Watchlist.Delete(app.contextGetUser(c).Id, _movieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) listWatchlistHandler(c *gin.Context) {
+	var input struct {
+		data.MovieSearch
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Title = app.readString(qs, "title", "")
+	input.Genres = app.readList(qs, "genres", []string{})
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "position")
+	input.Filters.SortSafeList = []string{"position", "priority", "added_at", "-position", "-priority", "-added_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	// movies which became hidden since they were added are left out of the listing
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	input.Public = !canSeeUnpublished
+
+	err = app.canonicalizeSearchGenres(v, &input.MovieSearch)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Watchlist.GetAll(app.contextGetUser(c).Id, input.MovieSearch, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	movieIds := make([]int64, len(items))
+	for i, item := range items {
+		movieIds[i] = item.MovieId
+	}
+	err = app.embedMovies(c, movieIds, func(i int, movie *data.Movie) { items[i].Movie = movie })
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     items,
+	})
+}
+
+func (app *application) markWatchedHandler(c *gin.Context) {
+	var input struct {
+		MovieId   int64  `json:"movie_id"`
+		WatchedAt string `json:"watched_at"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	entry := &data.WatchedEntry{
+		UserId:    app.contextGetUser(c).Id,
+		MovieId:   input.MovieId,
+		WatchedAt: time.Now().UTC().Truncate(24 * time.Hour),
+	}
+
+	v := validator.New()
+	if input.WatchedAt != "" {
+		entry.WatchedAt, err = time.Parse(time.DateOnly, input.WatchedAt)
+		v.Check(err == nil, "watched_at", "must be a date formatted as YYYY-MM-DD")
+	}
+	if data.ValidateWatchedEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	entry.Movie, err = app.getVisibleMovie(c, entry.MovieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("movie_id", "must refer to an existing movie")
+			app.failedValidationResponse(c, v.Errors)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	err = app.models.Watched.Insert(entry)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (app *application) deleteWatchedHandler(c *gin.Context) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	err := app.models.Watched.Delete(app.contextGetUser(c).Id, _id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (app *application) listWatchedHandler(c *gin.Context) {
+	var input struct {
+		data.MovieSearch
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Title = app.readString(qs, "title", "")
+	input.Genres = app.readList(qs, "genres", []string{})
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-watched_at")
+	input.Filters.SortSafeList = []string{"id", "watched_at", "-id", "-watched_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	// movies which became hidden since they were added are left out of the listing
+	canSeeUnpublished, err := app.canSeeUnpublished(c)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	input.Public = !canSeeUnpublished
+
+	err = app.canonicalizeSearchGenres(v, &input.MovieSearch)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	entries, metadata, err := app.models.Watched.GetAll(app.contextGetUser(c).Id, input.MovieSearch, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	movieIds := make([]int64, len(entries))
+	for i, entry := range entries {
+		movieIds[i] = entry.MovieId
+	}
+	err = app.embedMovies(c, movieIds, func(i int, movie *data.Movie) { entries[i].Movie = movie })
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     entries,
+	})
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/data"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookBatchSize is the number of deliveries claimed and sent at once
+	webhookBatchSize = 50
+
+	// webhookMaxBackoff caps the delay between two attempts of a failing delivery
+	webhookMaxBackoff = 6 * time.Hour
+)
+
+// runWebhookDeliveries sends the due webhook deliveries every interval until ctx is done, a zero interval
+// disables the deliveries
+func (app *application) runWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.deliverDueWebhooks(ctx)
+		}
+	}
+}
+
+// deliverDueWebhooks sends the due deliveries batch by batch until none is left
+func (app *application) deliverDueWebhooks(ctx context.Context) {
+	// a claimed delivery is left alone for as long as a send may take, plus some slack
+	lease := 2 * app.config.webhooks.timeout
+
+	for ctx.Err() == nil {
+		deliveries, err := app.models.Webhooks.ClaimDue(webhookBatchSize, lease)
+		if err != nil {
+			app.logger.Error(err, nil)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery *data.WebhookDelivery) {
+				defer wg.Done()
+				app.deliverWebhook(delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (app *application) deliverWebhook(delivery *data.WebhookDelivery) {
+	result, err := app.webhooks.Send(delivery.URL, delivery.Secret, delivery.Event, delivery.Id, delivery.Payload)
+
+	attempt := &data.WebhookAttempt{LatencyMs: int(result.Latency.Milliseconds())}
+	if result.StatusCode != 0 {
+		attempt.StatusCode = &result.StatusCode
+	}
+	switch {
+	case err != nil:
+		attempt.Error = err.Error()
+	case !result.OK():
+		attempt.Error = fmt.Sprintf("unexpected status code %d", result.StatusCode)
+	}
+
+	err = app.models.Webhooks.RecordAttempt(delivery, attempt, app.config.webhooks.maxAttempts,
+		app.config.webhooks.backoff, webhookMaxBackoff)
+	if err != nil {
+		app.logger.Error(err, nil)
+		return
+	}
+
+	if delivery.Status == data.DeliveryDead {
+		app.logger.Info("webhook delivery is dead", map[string]string{
+			"webhook_id":  strconv.FormatInt(delivery.WebhookId, 10),
+			"delivery_id": strconv.FormatInt(delivery.Id, 10),
+			"error":       delivery.LastError,
+		})
+	}
+}
+
+// readOwnWebhook fetches the webhook of the request user, the webhooks of other users are not found
+func (app *application) readOwnWebhook(c *gin.Context) (*data.Webhook, bool) {
+	id := c.Param("id")
+	_id, _ := strconv.ParseInt(id, 10, 64)
+
+	webhook, err := app.models.Webhooks.GetForUser(app.contextGetUser(c).Id, _id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return webhook, true
+}
+
+func (app *application) createWebhookHandler(c *gin.Context) {
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret *string  `json:"secret"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	webhook := &data.Webhook{
+		UserId: app.contextGetUser(c).Id,
+		URL:    input.URL,
+		Events: input.Events,
+		Active: true,
+	}
+
+	v := validator.New()
+	if input.Secret != nil {
+		v.Check(len(*input.Secret) >= 16, "secret", "must be at least 16 bytes long")
+		v.Check(len(*input.Secret) <= 256, "secret", "must not be more than 256 bytes long")
+		webhook.Secret = *input.Secret
+	}
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = data.GenerateWebhookSecret()
+		if err != nil {
+			app.serverErrorResponse(c, err)
+			return
+		}
+	}
+
+	err = app.models.Webhooks.Insert(webhook)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	// the secret is only ever shown once
+	c.Header("ETag", etag(webhook.Id, webhook.Version))
+	c.JSON(http.StatusCreated, map[string]interface{}{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+func (app *application) listWebhooksHandler(c *gin.Context) {
+	webhooks, err := app.models.Webhooks.GetAllForUser(app.contextGetUser(c).Id)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"data": webhooks,
+	})
+}
+
+func (app *application) showWebhookHandler(c *gin.Context) {
+	webhook, ok := app.readOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	if app.checkIfNoneMatch(c, etag(webhook.Id, webhook.Version)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (app *application) updateWebhookHandler(c *gin.Context) {
+	webhook, ok := app.readOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(webhook.Id, webhook.Version)) {
+		return
+	}
+
+	var input struct {
+		URL    *string  `json:"url"`
+		Events []string `json:"events"`
+		Active *bool    `json:"active"`
+	}
+	err := app.readJSON(c, &input)
+	if err != nil {
+		app.badRequestResponse(c, err)
+		return
+	}
+
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.Events != nil {
+		webhook.Events = input.Events
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	v := validator.New()
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	err = app.models.Webhooks.Update(webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.Header("ETag", etag(webhook.Id, webhook.Version))
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (app *application) deleteWebhookHandler(c *gin.Context) {
+	webhook, ok := app.readOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(c, etag(webhook.Id, webhook.Version)) {
+		return
+	}
+
+	err := app.models.Webhooks.Delete(webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// listWebhookDeliveriesHandler is the delivery log of the webhook, with the status code and latency of the
+// last attempt of each delivery
+func (app *application) listWebhookDeliveriesHandler(c *gin.Context) {
+	webhook, ok := app.readOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := c.Request.URL.Query()
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafeList = []string{"id", "-id"}
+
+	v.Check(input.Status == "" || validator.In(input.Status, data.DeliveryStatuses...), "status", "must be pending, succeeded or dead")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(c, v.Errors)
+		return
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.GetDeliveries(webhook.Id, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"metadata": metadata,
+		"data":     deliveries,
+	})
+}
+
+// readWebhookDelivery fetches a delivery of a webhook of the request user along with its attempts
+func (app *application) readWebhookDelivery(c *gin.Context) (*data.WebhookDelivery, bool) {
+	webhook, ok := app.readOwnWebhook(c)
+	if !ok {
+		return nil, false
+	}
+
+	deliveryId := c.Param("delivery_id")
+	_deliveryId, _ := strconv.ParseInt(deliveryId, 10, 64)
+
+	delivery, err := app.models.Webhooks.GetDelivery(webhook.Id, _deliveryId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(c)
+		default:
+			app.serverErrorResponse(c, err)
+		}
+		return nil, false
+	}
+	return delivery, true
+}
+
+func (app *application) showWebhookDeliveryHandler(c *gin.Context) {
+	delivery, ok := app.readWebhookDelivery(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// redeliverWebhookHandler queues the delivery again, typically once a dead delivery's receiver is fixed
+func (app *application) redeliverWebhookHandler(c *gin.Context) {
+	delivery, ok := app.readWebhookDelivery(c)
+	if !ok {
+		return
+	}
+
+	err := app.models.Webhooks.Redeliver(delivery)
+	if err != nil {
+		app.serverErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
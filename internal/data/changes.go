@@ -0,0 +1,123 @@
+package data
+
+import (
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// MovieChangesChannel is the channel LISTEN/NOTIFY announces new changes on
+const MovieChangesChannel = "movie_changes"
+
+// movieChangesLockKey identifies the advisory lock writers of the change feed hold until they commit
+const movieChangesLockKey int64 = 0x676c5f6368616e67
+
+// MovieChange is an entry of the change feed, deleted movies leave a tombstone at their last version
+type MovieChange struct {
+	Seq       int64     `json:"-"`
+	MovieId   int64     `json:"movie_id"`
+	Version   int32     `json:"version"`
+	Kind      string    `json:"kind"`
+	ChangedAt time.Time `json:"changed_at"`
+	// Public tells whether the public can currently see the movie
+	Public bool `json:"-" gorm:"->"`
+}
+
+// ForPublic returns the change as the public sees it, movies the public can't see anymore are deleted
+func (change *MovieChange) ForPublic() *MovieChange {
+	if change.Public || change.Kind == ChangeDeleted {
+		return change
+	}
+	public := *change
+	public.Kind = ChangeDeleted
+	return &public
+}
+
+type MovieChangeModel struct {
+	DB *gorm.DB
+}
+
+// GetAll returns the changes which come after the since sequence number in commit order
+func (m *MovieChangeModel) GetAll(since int64, limit int) ([]*MovieChange, error) {
+	changes := []*MovieChange{}
+	query := m.DB.Table("movie_changes").
+		Select("movie_changes.*, EXISTS (SELECT 1 FROM movies WHERE movies.id = movie_changes.movie_id AND "+
+			publicMovieCondition+") AS public").
+		Where("seq > ?", since).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&changes)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return changes, nil
+}
+
+// LastSeq returns the sequence number of the latest change
+func (m *MovieChangeModel) LastSeq() (int64, error) {
+	var seq int64
+	err := m.DB.Table("movie_changes").Select("coalesce(max(seq), 0)").Scan(&seq).Error
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// recordMovieChanges appends the movies to the change feed within the transaction which changed them. The
+// sequence numbers are handed out under an advisory lock held until commit so they follow the commit order,
+// which lets clients resume from the last sequence number they saw without missing a change. Webhook deliveries
+// are queued along and listeners of the movie_changes channel are notified on commit. It must be the last
+// statement of the transaction to keep the lock short and ordered after the row locks
+func recordMovieChanges(tx *gorm.DB, kind string, movies ...*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	err := tx.Exec("SELECT pg_advisory_xact_lock(?)", movieChangesLockKey).Error
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, len(movies))
+	versions := make([]int32, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.Id
+		versions[i] = movie.Version
+	}
+
+	query := `
+		INSERT INTO movie_changes (movie_id, version, kind)
+		SELECT movie_id, version, ? FROM unnest(?::bigint[], ?::integer[]) AS changed (movie_id, version)
+		RETURNING seq`
+
+	var seqs []int64
+	err = tx.Raw(query, kind, pq.Array(ids), pq.Array(versions)).Scan(&seqs).Error
+	if err != nil {
+		return err
+	}
+	err = enqueueWebhookDeliveries(tx, seqs)
+	if err != nil {
+		return err
+	}
+	return tx.Exec("SELECT pg_notify(?, '')", MovieChangesChannel).Error
+}
+
+// recordMovieTouched gives the movie a new version and records the change, for changes to the resources
+// embedded in the movie. Like recordMovieChanges it must be the last statement of the transaction
+func recordMovieTouched(tx *gorm.DB, movieId int64) error {
+	var movie Movie
+	result := tx.Raw("UPDATE movies SET version = version + 1 WHERE id = ? RETURNING id, title, version", movieId).Scan(&movie)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return recordMovieChanges(tx, ChangeUpdated, &movie)
+}
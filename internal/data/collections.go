@@ -0,0 +1,180 @@
+package data
+
+import (
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"time"
+)
+
+// MaxCollectionMovies caps the number of movies of a single collection
+const MaxCollectionMovies = 200
+
+type CollectionModel struct {
+	DB *gorm.DB
+}
+
+func (m *CollectionModel) Insert(collection *Collection) error {
+	return m.DB.Table("collections").Create(collection).Error
+}
+
+func (m *CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var collection Collection
+	query := m.DB.Table("collections").Find(&collection, id)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &collection, nil
+}
+
+func (m *CollectionModel) Update(collection *Collection) error {
+	query := `
+		UPDATE collections
+		SET name = ?, description = ?, version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version`
+
+	tx := m.DB.Raw(query, collection.Name, collection.Description, collection.Id, collection.Version).Scan(&collection.Version)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// Delete removes the collection as long as it is still at the given version, the movies themselves are kept
+func (m *CollectionModel) Delete(collection *Collection) error {
+	query := m.DB.Table("collections").Where("id = ? AND version = ?", collection.Id, collection.Version).Delete(&Collection{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (m *CollectionModel) GetAll(name string, filters Filters) ([]*Collection, Metadata, error) {
+	var listCollections []struct {
+		Count int
+		*Collection
+	}
+	q := m.DB.Table("collections").
+		Where(`to_tsvector('simple', name) @@ plainto_tsquery('simple', @name) OR @name = ''`,
+			map[string]interface{}{"name": name}).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, *").
+		Find(&listCollections)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	collections := []*Collection{}
+	if len(listCollections) == 0 {
+		return collections, Metadata{}, nil
+	}
+	for _, item := range listCollections {
+		collections = append(collections, item.Collection)
+	}
+	metadata := calculateMetadata(listCollections[0].Count, filters.Page, filters.PageSize)
+	return collections, metadata, nil
+}
+
+// GetMovieIds returns the ids of the movies of the collection in order
+func (m *CollectionModel) GetMovieIds(collectionId int64) ([]int64, error) {
+	movieIds := []int64{}
+	query := m.DB.Table("collection_movies").
+		Where("collection_id = ?", collectionId).
+		Order("position ASC").
+		Pluck("movie_id", &movieIds)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return movieIds, nil
+}
+
+// SetMovies replaces the movies of the collection by movieIds in that order, it fails with ErrEditConflict
+// when the collection changed since it was read
+func (m *CollectionModel) SetMovies(collection *Collection, movieIds []int64) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Raw("UPDATE collections SET version = version + 1 WHERE id = ? AND version = ? RETURNING version",
+			collection.Id, collection.Version).Scan(&collection.Version)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		err := tx.Exec("DELETE FROM collection_movies WHERE collection_id = ?", collection.Id).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			INSERT INTO collection_movies (collection_id, movie_id, position)
+			SELECT ?, ordered.movie_id, ordered.position
+			FROM unnest(?::bigint[]) WITH ORDINALITY AS ordered(movie_id, position)`,
+			collection.Id, pq.Array(movieIds)).Error
+	})
+}
+
+// GetForMovies returns the collections each movie belongs to along with its position in them
+func (m *CollectionModel) GetForMovies(movieIds []int64) (map[int64][]*CollectionMembership, error) {
+	var memberships []*CollectionMembership
+	query := m.DB.Table("collection_movies").
+		Select(`collection_movies.collection_id, collection_movies.movie_id, collections.name, collection_movies.position,
+			(SELECT count(*) FROM collection_movies AS members WHERE members.collection_id = collection_movies.collection_id) AS total`).
+		Joins("INNER JOIN collections ON collections.id = collection_movies.collection_id").
+		Where("collection_movies.movie_id = ANY(?)", pq.Array(movieIds)).
+		Order("collections.name, collections.id").
+		Find(&memberships)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	membershipsByMovie := make(map[int64][]*CollectionMembership, len(movieIds))
+	for _, id := range movieIds {
+		membershipsByMovie[id] = []*CollectionMembership{}
+	}
+	for _, membership := range memberships {
+		membershipsByMovie[membership.MovieId] = append(membershipsByMovie[membership.MovieId], membership)
+	}
+	return membershipsByMovie, nil
+}
+
+type Collection struct {
+	Id          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     int32     `json:"version"`
+	Movies      []*Movie  `json:"movies,omitempty" gorm:"-"`
+}
+
+// CollectionMembership places a movie in a collection, e.g. part 3 of 8
+type CollectionMembership struct {
+	CollectionId int64  `json:"collection_id"`
+	MovieId      int64  `json:"-"`
+	Name         string `json:"name"`
+	Position     int32  `json:"position"`
+	Total        int32  `json:"total"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(len(collection.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+}
@@ -0,0 +1,97 @@
+package data
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("duplicate credit")
+)
+
+var CreditRoles = []string{"director", "writer", "actor"}
+
+type CreditModel struct {
+	DB *gorm.DB
+}
+
+// Insert adds the credit, the credits are part of the movie so the movie gets a new version and the change
+// is recorded in the same transaction
+func (m *CreditModel) Insert(credit *Credit) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("movie_credits").Create(credit)
+		if query.Error != nil {
+			switch {
+			case query.Error.Error() == `ERROR: duplicate key value violates unique constraint "movie_credits_movie_id_person_id_role_character_name_key" (SQLSTATE 23505)`:
+				return ErrDuplicateCredit
+			default:
+				return query.Error
+			}
+		}
+		return recordMovieTouched(tx, credit.MovieId)
+	})
+}
+
+// GetForMovies returns the credits of each movie, directors and writers first then actors by billing order
+func (m *CreditModel) GetForMovies(movieIds []int64) (map[int64][]*Credit, error) {
+	var credits []*Credit
+	query := m.DB.Table("movie_credits").
+		Select("movie_credits.*, people.name AS person_name").
+		Joins("INNER JOIN people ON people.id = movie_credits.person_id").
+		Where("movie_credits.movie_id = ANY(?)", pq.Array(movieIds)).
+		Order("array_position(ARRAY['director', 'writer', 'actor'], movie_credits.role), movie_credits.billing_order, movie_credits.id").
+		Find(&credits)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	creditsByMovie := make(map[int64][]*Credit, len(movieIds))
+	for _, id := range movieIds {
+		creditsByMovie[id] = []*Credit{}
+	}
+	for _, credit := range credits {
+		creditsByMovie[credit.MovieId] = append(creditsByMovie[credit.MovieId], credit)
+	}
+	return creditsByMovie, nil
+}
+
+// Delete removes the credit of the movie, like Insert it gives the movie a new version
+func (m *CreditModel) Delete(movieId, id int64) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("movie_credits").Where("id = ? AND movie_id = ?", id, movieId).Delete(&Credit{})
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		return recordMovieTouched(tx, movieId)
+	})
+}
+
+type Credit struct {
+	Id           int64  `json:"id"`
+	MovieId      int64  `json:"movie_id"`
+	PersonId     int64  `json:"person_id"`
+	PersonName   string `json:"person_name,omitempty" gorm:"->"`
+	Role         string `json:"role"`
+	Character    string `json:"character,omitempty" gorm:"column:character_name"`
+	BillingOrder int32  `json:"billing_order"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonId > 0, "person_id", "must be provided")
+
+	v.Check(credit.Role != "", "role", "must be provided")
+	v.Check(validator.In(credit.Role, CreditRoles...), "role", "must be one of director, writer or actor")
+
+	if credit.Role == "actor" {
+		v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+	} else {
+		v.Check(credit.Character == "", "character", "must only be provided for actors")
+	}
+
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
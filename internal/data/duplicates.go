@@ -0,0 +1,187 @@
+package data
+
+import (
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+// FindDuplicates returns the ids of the movies which look like the same film: the same title, ignoring case
+// and diacritics, released the same year, or a title whose trigram similarity reaches threshold released
+// within a year of it. Remakes decades apart are not duplicates. The similarity is matched with the % operator
+// so the trigram index on titles is used, its threshold is set for the transaction only
+func (m *MovieModel) FindDuplicates(movie *Movie, threshold float64) ([]int64, error) {
+	query := `
+		SELECT id
+		FROM movies
+		WHERE id <> @id AND (
+			(lower(immutable_unaccent(title)) = lower(immutable_unaccent(@title)) AND year = @year)
+			OR (lower(immutable_unaccent(title)) % lower(immutable_unaccent(@title)) AND abs(year - @year) <= 1)
+		)
+		ORDER BY similarity(lower(immutable_unaccent(title)), lower(immutable_unaccent(@title))) DESC, id ASC
+		LIMIT 10`
+
+	ids := []int64{}
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT set_config('pg_trgm.similarity_threshold', ?, true)", strconv.FormatFloat(threshold, 'f', -1, 64)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Raw(query, map[string]interface{}{
+			"id":    movie.Id,
+			"title": movie.Title,
+			"year":  movie.Year,
+		}).Scan(&ids).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Merge folds the duplicate into the survivor in a single transaction: reviews, credits, external ids, list,
+// watchlist, watched and collection entries move to the survivor unless it already has an equivalent one, then the
+// duplicate is deleted and its id redirects to the survivor. Both movies must still be at the version they were
+// read at, the survivor gets a new version
+func (m *MovieModel) Merge(survivor, duplicate *Movie) error {
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		// lock both movies in id order so two merges can't deadlock
+		first, second := survivor.Id, duplicate.Id
+		if first > second {
+			first, second = second, first
+		}
+		for _, id := range []int64{first, second} {
+			err := lockMovie(tx, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		query := tx.Table("movies").Where("id = ? AND version = ?", duplicate.Id, duplicate.Version).Select("id").Find(&Movie{})
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		// the survivor takes in the duplicate, it changes as if it had been edited
+		query = tx.Raw("UPDATE movies SET version = version + 1 WHERE id = ? AND version = ? RETURNING version",
+			survivor.Id, survivor.Version).Scan(&survivor.Version)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		var listIds, collectionIds, watchlistUserIds []int64
+		err := tx.Table("movie_list_items").Where("movie_id = ?", duplicate.Id).Pluck("list_id", &listIds).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Table("collection_movies").Where("movie_id = ?", duplicate.Id).Pluck("collection_id", &collectionIds).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Table("watchlist_items").Where("movie_id = ?", duplicate.Id).Pluck("user_id", &watchlistUserIds).Error
+		if err != nil {
+			return err
+		}
+
+		statements := []string{
+			`UPDATE reviews SET movie_id = @survivor
+			WHERE movie_id = @duplicate
+				AND user_id NOT IN (SELECT user_id FROM reviews WHERE movie_id = @survivor)`,
+			`UPDATE movie_credits SET movie_id = @survivor
+			WHERE movie_id = @duplicate AND NOT EXISTS (
+				SELECT 1 FROM movie_credits AS kept
+				WHERE kept.movie_id = @survivor AND kept.person_id = movie_credits.person_id
+					AND kept.role = movie_credits.role AND kept.character_name = movie_credits.character_name)`,
+			`UPDATE movie_list_items SET movie_id = @survivor
+			WHERE movie_id = @duplicate
+				AND list_id NOT IN (SELECT list_id FROM movie_list_items WHERE movie_id = @survivor)`,
+			`UPDATE collection_movies SET movie_id = @survivor
+			WHERE movie_id = @duplicate
+				AND collection_id NOT IN (SELECT collection_id FROM collection_movies WHERE movie_id = @survivor)`,
+			`UPDATE watchlist_items SET movie_id = @survivor
+			WHERE movie_id = @duplicate
+				AND user_id NOT IN (SELECT user_id FROM watchlist_items WHERE movie_id = @survivor)`,
+			`UPDATE watched_movies SET movie_id = @survivor WHERE movie_id = @duplicate`,
+			`UPDATE movie_external_ids SET movie_id = @survivor
+			WHERE movie_id = @duplicate
+				AND source NOT IN (SELECT source FROM movie_external_ids WHERE movie_id = @survivor)`,
+			`UPDATE movie_redirects SET movie_id = @survivor WHERE movie_id = @duplicate`,
+			`INSERT INTO movie_redirects (old_id, movie_id) VALUES (@duplicate, @survivor)`,
+			// whatever wasn't moved goes away with the duplicate
+			`DELETE FROM movies WHERE id = @duplicate`,
+		}
+		args := map[string]interface{}{"survivor": survivor.Id, "duplicate": duplicate.Id}
+		for _, statement := range statements {
+			err = tx.Exec(statement, args).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// entries which were dropped leave gaps in the positions, and lists and collections changed under their editors
+		renumbers := []struct {
+			statement string
+			ids       []int64
+		}{
+			{`UPDATE movie_lists SET version = version + 1 WHERE id = ANY(?)`, listIds},
+			{`UPDATE movie_list_items SET position = ranked.position
+			FROM (SELECT list_id, movie_id, row_number() OVER (PARTITION BY list_id ORDER BY position, movie_id) AS position
+				FROM movie_list_items WHERE list_id = ANY(?)) AS ranked
+			WHERE movie_list_items.list_id = ranked.list_id AND movie_list_items.movie_id = ranked.movie_id`, listIds},
+			{`UPDATE collections SET version = version + 1 WHERE id = ANY(?)`, collectionIds},
+			{`UPDATE collection_movies SET position = ranked.position
+			FROM (SELECT collection_id, movie_id, row_number() OVER (PARTITION BY collection_id ORDER BY position, movie_id) AS position
+				FROM collection_movies WHERE collection_id = ANY(?)) AS ranked
+			WHERE collection_movies.collection_id = ranked.collection_id AND collection_movies.movie_id = ranked.movie_id`, collectionIds},
+			{`UPDATE watchlist_items SET position = ranked.position
+			FROM (SELECT id, row_number() OVER (PARTITION BY user_id ORDER BY position, id) AS position
+				FROM watchlist_items WHERE user_id = ANY(?)) AS ranked
+			WHERE watchlist_items.id = ranked.id`, watchlistUserIds},
+		}
+		for _, renumber := range renumbers {
+			if len(renumber.ids) == 0 {
+				continue
+			}
+			err = tx.Exec(renumber.statement, pq.Array(renumber.ids)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		rated, err := refreshMovieRating(tx, survivor.Id)
+		if err != nil {
+			return err
+		}
+		survivor.Version = rated.Version
+		err = recordMovieChanges(tx, ChangeDeleted, duplicate)
+		if err != nil {
+			return err
+		}
+		return recordMovieChanges(tx, ChangeUpdated, survivor)
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(duplicate.Id, "")
+	m.suggestions.invalidate(survivor.Id, survivor.Title)
+	return nil
+}
+
+// GetRedirect returns the id of the movie a merged movie id now points to
+func (m *MovieModel) GetRedirect(oldId int64) (int64, error) {
+	var movieId int64
+	query := m.DB.Table("movie_redirects").Where("old_id = ?", oldId).Select("movie_id").Scan(&movieId)
+	if query.Error != nil {
+		return 0, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return 0, ErrRecordNotFound
+	}
+	return movieId, nil
+}
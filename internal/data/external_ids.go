@@ -0,0 +1,204 @@
+package data
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrDuplicateExternalId = errors.New("duplicate external id")
+)
+
+// ExternalIdFormats maps the outside datasets movies are reconciled against to the format of their ids
+var ExternalIdFormats = map[string]*regexp.Regexp{
+	"imdb":     validator.ImdbIdRX,
+	"tmdb":     validator.TmdbIdRX,
+	"wikidata": validator.WikidataIdRX,
+}
+
+var ExternalSources = []string{"imdb", "tmdb", "wikidata"}
+
+type ExternalIdModel struct {
+	DB *gorm.DB
+}
+
+// Set maps the external id to the movie, replacing the id the movie had for that source
+func (m *ExternalIdModel) Set(externalId *ExternalId) error {
+	query := `
+		INSERT INTO movie_external_ids (movie_id, source, external_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT (movie_id, source) DO UPDATE SET external_id = EXCLUDED.external_id, created_at = NOW()
+		RETURNING created_at`
+
+	tx := m.DB.Raw(query, externalId.MovieId, externalId.Source, externalId.ExternalId).Scan(&externalId.CreatedAt)
+	if tx.Error != nil {
+		switch {
+		case tx.Error.Error() == `ERROR: duplicate key value violates unique constraint "movie_external_ids_source_external_id_key" (SQLSTATE 23505)`:
+			return ErrDuplicateExternalId
+		default:
+			return tx.Error
+		}
+	}
+	return nil
+}
+
+// GetMovieId resolves an external id to the id of the movie it is mapped to
+func (m *ExternalIdModel) GetMovieId(source, externalId string) (int64, error) {
+	var movieId int64
+	query := m.DB.Table("movie_external_ids").
+		Where("source = ? AND external_id = ?", source, externalId).
+		Select("movie_id").
+		Scan(&movieId)
+	if query.Error != nil {
+		return 0, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return 0, ErrRecordNotFound
+	}
+	return movieId, nil
+}
+
+// GetForMovies returns the external ids of each movie
+func (m *ExternalIdModel) GetForMovies(movieIds []int64) (map[int64][]*ExternalId, error) {
+	var externalIds []*ExternalId
+	query := m.DB.Table("movie_external_ids").
+		Where("movie_id = ANY(?)", pq.Array(movieIds)).
+		Order("source").
+		Find(&externalIds)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	externalIdsByMovie := make(map[int64][]*ExternalId, len(movieIds))
+	for _, id := range movieIds {
+		externalIdsByMovie[id] = []*ExternalId{}
+	}
+	for _, externalId := range externalIds {
+		externalIdsByMovie[externalId.MovieId] = append(externalIdsByMovie[externalId.MovieId], externalId)
+	}
+	return externalIdsByMovie, nil
+}
+
+func (m *ExternalIdModel) Delete(movieId int64, source string) error {
+	query := m.DB.Table("movie_external_ids").Where("movie_id = ? AND source = ?", movieId, source).Delete(&ExternalId{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+type ExternalId struct {
+	MovieId    int64     `json:"-"`
+	Source     string    `json:"source"`
+	ExternalId string    `json:"external_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidateExternalSource(v *validator.Validator, source string) {
+	v.Check(validator.In(source, ExternalSources...), "source", "must be imdb, tmdb or wikidata")
+}
+
+func ValidateExternalId(v *validator.Validator, externalId *ExternalId) {
+	ValidateExternalSource(v, externalId.Source)
+
+	v.Check(externalId.ExternalId != "", "external_id", "must be provided")
+	if rx, ok := ExternalIdFormats[externalId.Source]; ok {
+		v.Check(validator.Matches(externalId.ExternalId, rx), "external_id", "must be a valid "+externalId.Source+" id")
+	}
+}
+
+// UpsertByExternalId stores a batch of movies identified in an outside dataset in a single transaction,
+// externalIds[i] being the id of movies[i] in source. Movies already mapped to their external id are
+// updated, their version only moving when something changed, the others are inserted along with their mapping
+func (m *MovieModel) UpsertByExternalId(source string, externalIds []string, movies []*Movie) (int, int, error) {
+	inserted, updated := 0, 0
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		var mapped []struct {
+			MovieId    int64
+			ExternalId string
+		}
+		err := tx.Table("movie_external_ids").
+			Select("movie_id, external_id").
+			Where("source = ? AND external_id = ANY(?)", source, pq.Array(externalIds)).
+			Scan(&mapped).Error
+		if err != nil {
+			return err
+		}
+		movieIds := make(map[string]int64, len(mapped))
+		for _, row := range mapped {
+			movieIds[row.ExternalId] = row.MovieId
+		}
+
+		query := `
+			UPDATE movies
+			SET title = @title, year = @year, runtime = @runtime, genres = @genres, version = version + 1
+			WHERE id = @id AND (title, year, runtime, genres) IS DISTINCT FROM (@title, @year, @runtime, @genres::text[])
+			RETURNING version`
+
+		var newMovies, updatedMovies []*Movie
+		var newExternalIds []string
+		for i, movie := range movies {
+			id, ok := movieIds[externalIds[i]]
+			if !ok {
+				newMovies = append(newMovies, movie)
+				newExternalIds = append(newExternalIds, externalIds[i])
+				continue
+			}
+
+			movie.Id = id
+			result := tx.Raw(query, map[string]interface{}{
+				"id":      movie.Id,
+				"title":   movie.Title,
+				"year":    movie.Year,
+				"runtime": movie.Runtime,
+				"genres":  movie.Genres,
+			}).Scan(&movie.Version)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				updatedMovies = append(updatedMovies, movie)
+			}
+		}
+		updated = len(updatedMovies)
+
+		if len(newMovies) == 0 {
+			return recordMovieChanges(tx, ChangeUpdated, updatedMovies...)
+		}
+		err = tx.Table("movies").Create(&newMovies).Error
+		if err != nil {
+			return err
+		}
+
+		mappings := make([]*ExternalId, len(newMovies))
+		for i, movie := range newMovies {
+			mappings[i] = &ExternalId{MovieId: movie.Id, Source: source, ExternalId: newExternalIds[i]}
+		}
+		err = tx.Table("movie_external_ids").Create(&mappings).Error
+		if err != nil {
+			return err
+		}
+		inserted = len(newMovies)
+
+		err = recordMovieChanges(tx, ChangeUpdated, updatedMovies...)
+		if err != nil {
+			return err
+		}
+		return recordMovieChanges(tx, ChangeCreated, newMovies...)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, movie := range movies {
+		m.suggestions.invalidate(movie.Id, movie.Title)
+	}
+	return inserted, updated, nil
+}
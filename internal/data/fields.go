@@ -0,0 +1,51 @@
+package data
+
+import (
+	"github.com/duongbm/greenlight-gin/internal/validator"
+)
+
+// Fields holds a sparse fieldset and the related resources to embed in a response
+type Fields struct {
+	Fields          []string
+	FieldSafeList   []string
+	Include         []string
+	IncludeSafeList []string
+}
+
+// Columns returns the columns to select for the requested fieldset,
+// id is always selected so related resources can be embedded
+func (f Fields) Columns() []string {
+	if len(f.Fields) == 0 {
+		return nil
+	}
+
+	columns := []string{"id"}
+	for _, field := range f.Fields {
+		if !validator.In(field, f.FieldSafeList...) {
+			panic("unsafe field parameter: " + field)
+		}
+		if field != "id" {
+			columns = append(columns, field)
+		}
+	}
+	return columns
+}
+
+func ValidateFields(v *validator.Validator, f Fields) {
+	for _, field := range f.Fields {
+		v.Check(validator.In(field, f.FieldSafeList...), "fields", "invalid field value")
+	}
+	v.Check(validator.Unique(f.Fields), "fields", "must not contain duplicate fields")
+
+	for _, include := range f.Include {
+		v.Check(validator.In(include, f.IncludeSafeList...), "include", "invalid include value")
+	}
+	v.Check(validator.Unique(f.Include), "include", "must not contain duplicate values")
+}
+
+func selectColumns(columns []string) []string {
+	if len(columns) == 0 {
+		return []string{"*"}
+	}
+	return columns
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"fmt"
 	"github.com/duongbm/greenlight-gin/internal/validator"
 	"math"
 	"strings"
@@ -21,20 +22,35 @@ type Metadata struct {
 	TotalRecords int `json:"total_records,omitempty"`
 }
 
-func (f *Filters) sortColum() string {
-	for _, safeValue := range f.SortSafeList {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
+func (f *Filters) sortKeys() []string {
+	return strings.Split(f.Sort, ",")
+}
+
+// orderBy builds the ORDER BY clause from the safelisted sort keys,
+// id is always used as the final tie-breaker so pages stay stable
+func (f *Filters) orderBy() string {
+	var clauses []string
+	sortedById := false
+	for _, key := range f.sortKeys() {
+		if !validator.In(key, f.SortSafeList...) {
+			panic("unsafe sort parameter: " + key)
+		}
+
+		column := strings.TrimPrefix(key, "-")
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			direction = "DESC"
 		}
+		if column == "id" {
+			sortedById = true
+		}
+		clauses = append(clauses, fmt.Sprintf("%s %s", column, direction))
 	}
-	panic("unsafe sort parameter: " + f.Sort)
-}
 
-func (f *Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+	if !sortedById {
+		clauses = append(clauses, "id ASC")
 	}
-	return "ASC"
+	return strings.Join(clauses, ", ")
 }
 
 func (f *Filters) limit() int {
@@ -51,7 +67,12 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize < 100, "page_size", "must be maximum of 100")
 
-	v.Check(validator.In(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+	var columns []string
+	for _, key := range f.sortKeys() {
+		v.Check(validator.In(key, f.SortSafeList...), "sort", "invalid sort value")
+		columns = append(columns, strings.TrimPrefix(key, "-"))
+	}
+	v.Check(validator.Unique(columns), "sort", "must not contain duplicate or conflicting keys")
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
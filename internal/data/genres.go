@@ -0,0 +1,302 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrGenreInUse     = errors.New("genre in use")
+)
+
+var (
+	SlugRX        = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	genreSpacesRX = regexp.MustCompile(`[\s_]+`)
+)
+
+type GenreModel struct {
+	DB *gorm.DB
+}
+
+func (m *GenreModel) Insert(genre *Genre) error {
+	query := m.DB.Table("genres").Create(genre)
+	if query.Error != nil {
+		switch {
+		case query.Error.Error() == `ERROR: duplicate key value violates unique constraint "genres_slug_key" (SQLSTATE 23505)`:
+			return ErrDuplicateGenre
+		default:
+			return query.Error
+		}
+	}
+	return nil
+}
+
+func (m *GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var genre Genre
+	query := m.DB.Table("genres").Find(&genre, id)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &genre, nil
+}
+
+func (m *GenreModel) GetAll() ([]*Genre, error) {
+	genres := []*Genre{}
+	query := m.DB.Table("genres").Order("slug ASC").Find(&genres)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return genres, nil
+}
+
+// Update saves the genre, when the slug changes the movies tagged with the old slug are retagged in the same
+// transaction and get a new version
+func (m *GenreModel) Update(genre *Genre) error {
+	query := `
+		UPDATE genres
+		SET slug = ?, name = ?, aliases = ?, version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version`
+
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		var previous string
+		result := tx.Raw("SELECT slug FROM genres WHERE id = ? FOR UPDATE", genre.Id).Scan(&previous)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Raw(query, genre.Slug, genre.Name, genre.Aliases, genre.Id, genre.Version).Scan(&genre)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+		if previous == genre.Slug {
+			return nil
+		}
+
+		var retagged []*Movie
+		err := tx.Raw(`
+			UPDATE movies
+			SET genres = CASE WHEN @slug = ANY(genres) THEN array_remove(genres, @previous)
+				ELSE array_replace(genres, @previous, @slug) END,
+				version = version + 1
+			WHERE genres @> ARRAY[@previous]::text[]
+			RETURNING id, version`,
+			map[string]interface{}{"slug": genre.Slug, "previous": previous}).Scan(&retagged).Error
+		if err != nil {
+			return err
+		}
+		return recordMovieChanges(tx, ChangeUpdated, retagged...)
+	})
+	if err != nil {
+		switch {
+		case err.Error() == `ERROR: duplicate key value violates unique constraint "genres_slug_key" (SQLSTATE 23505)`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the genre, ErrGenreInUse is returned while movies are still tagged with it as removing the
+// tag could leave them without any genre
+func (m *GenreModel) Delete(genre *Genre) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		var slug string
+		query := tx.Raw("SELECT slug FROM genres WHERE id = ? AND version = ? FOR UPDATE", genre.Id, genre.Version).Scan(&slug)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		var used bool
+		err := tx.Raw("SELECT EXISTS (SELECT 1 FROM movies WHERE genres @> ARRAY[?]::text[])", slug).Scan(&used).Error
+		if err != nil {
+			return err
+		}
+		if used {
+			return ErrGenreInUse
+		}
+
+		return tx.Table("genres").Where("id = ?", genre.Id).Delete(&Genre{}).Error
+	})
+}
+
+// Index loads every genre into a GenreIndex
+func (m *GenreModel) Index() (GenreIndex, error) {
+	known, err := m.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return newGenreIndex(known), nil
+}
+
+// Canonicalize maps free-form genres onto canonical slugs, see GenreIndex.Canonicalize
+func (m *GenreModel) Canonicalize(genres []string) ([]string, map[string]string, error) {
+	index, err := m.Index()
+	if err != nil {
+		return nil, nil, err
+	}
+	canonical, unknown := index.Canonicalize(genres)
+	return canonical, unknown, nil
+}
+
+// Conflicts returns the slug, name and aliases of genre which already resolve to another genre
+func (m *GenreModel) Conflicts(genre *Genre) ([]string, error) {
+	known, err := m.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var others []*Genre
+	for _, g := range known {
+		if g.Id != genre.Id {
+			others = append(others, g)
+		}
+	}
+	index := newGenreIndex(others)
+
+	var conflicts []string
+	for _, name := range append([]string{genre.Slug, genre.Name}, genre.Aliases...) {
+		if _, ok := index[NormalizeGenre(name)]; ok {
+			conflicts = append(conflicts, name)
+		}
+	}
+	return conflicts, nil
+}
+
+// GenreIndex maps normalized slugs, display names and aliases to canonical slugs
+type GenreIndex map[string]string
+
+func newGenreIndex(genres []*Genre) GenreIndex {
+	index := GenreIndex{}
+	for _, genre := range genres {
+		index[NormalizeGenre(genre.Name)] = genre.Slug
+		for _, alias := range genre.Aliases {
+			index[NormalizeGenre(alias)] = genre.Slug
+		}
+	}
+	// slugs win over names and aliases of other genres
+	for _, genre := range genres {
+		index[genre.Slug] = genre.Slug
+	}
+	return index
+}
+
+// Canonicalize maps free-form genres onto canonical slugs through the slugs, display names and aliases,
+// unknown genres are returned with the closest known slug as a suggestion
+func (index GenreIndex) Canonicalize(genres []string) ([]string, map[string]string) {
+	canonical := []string{}
+	unknown := map[string]string{}
+	for _, genre := range genres {
+		slug, ok := index[NormalizeGenre(genre)]
+		if !ok {
+			unknown[genre] = index.suggest(genre)
+			continue
+		}
+		// "Sci-Fi" and "science fiction" are the same genre once canonicalized
+		if !validator.In(slug, canonical...) {
+			canonical = append(canonical, slug)
+		}
+	}
+	return canonical, unknown
+}
+
+func (index GenreIndex) suggest(genre string) string {
+	genre = NormalizeGenre(genre)
+	suggestion := ""
+	best := len(genre)/3 + 2
+	for name, slug := range index {
+		distance := levenshtein(genre, name)
+		if distance < best || (distance == best && slug < suggestion) {
+			best = distance
+			suggestion = slug
+		}
+	}
+	return suggestion
+}
+
+// NormalizeGenre lowercases a genre and joins its words with hyphens, "Science Fiction" becomes "science-fiction"
+func NormalizeGenre(genre string) string {
+	return genreSpacesRX.ReplaceAllString(strings.ToLower(strings.TrimSpace(genre)), "-")
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+type Genre struct {
+	Id        int64          `json:"id"`
+	CreatedAt time.Time      `json:"-"`
+	Slug      string         `json:"slug"`
+	Name      string         `json:"name"`
+	Aliases   pq.StringArray `json:"aliases" gorm:"type:text[]"`
+	Version   int32          `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+	v.Check(validator.Matches(genre.Slug, SlugRX), "slug", "must only contain lowercase letters, digits and single hyphens")
+
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(genre.Aliases != nil, "aliases", "must be provided")
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contain more than 20 aliases")
+	for _, alias := range genre.Aliases {
+		v.Check(NormalizeGenre(alias) != "", "aliases", "must not contain empty aliases")
+	}
+	v.Check(validator.Unique(genre.Aliases), "aliases", "must not contain duplicate aliases")
+}
+
+// UnknownGenresMessage describes unknown genres along with their suggestions
+func UnknownGenresMessage(unknown map[string]string) string {
+	var messages []string
+	for genre, suggestion := range unknown {
+		if suggestion == "" {
+			messages = append(messages, fmt.Sprintf("unknown genre %q", genre))
+		} else {
+			messages = append(messages, fmt.Sprintf("unknown genre %q, did you mean %q?", genre, suggestion))
+		}
+	}
+	sort.Strings(messages)
+	return strings.Join(messages, "; ")
+}
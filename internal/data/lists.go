@@ -0,0 +1,282 @@
+package data
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ListPrivate  = "private"
+	ListUnlisted = "unlisted"
+	ListPublic   = "public"
+
+	// MaxListItems caps the number of movies of a single list
+	MaxListItems = 500
+)
+
+var (
+	ErrDuplicateListItem = errors.New("duplicate list item")
+)
+
+var (
+	listSlugRX = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type MovieListModel struct {
+	DB *gorm.DB
+}
+
+// Insert stores the list under a new slug derived from its title
+func (m *MovieListModel) Insert(list *MovieList) error {
+	var err error
+	// the random suffix makes collisions unlikely, retry a few times when one happens anyway
+	for attempt := 0; attempt < 3; attempt++ {
+		list.Slug, err = generateListSlug(list.Title)
+		if err != nil {
+			return err
+		}
+
+		err = m.DB.Table("movie_lists").Create(list).Error
+		if err == nil || err.Error() != `ERROR: duplicate key value violates unique constraint "movie_lists_slug_key" (SQLSTATE 23505)` {
+			return err
+		}
+	}
+	return err
+}
+
+func (m *MovieListModel) GetBySlug(slug string) (*MovieList, error) {
+	var list MovieList
+	query := m.DB.Table("movie_lists").
+		Select("movie_lists.*, (SELECT count(*) FROM movie_list_items WHERE list_id = movie_lists.id) AS item_count").
+		Where("slug = ?", slug).
+		Find(&list)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &list, nil
+}
+
+// Update saves the title, description and visibility of the list, the slug never changes so shared links keep working
+func (m *MovieListModel) Update(list *MovieList) error {
+	query := `
+		UPDATE movie_lists
+		SET title = ?, description = ?, visibility = ?, version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version`
+
+	tx := m.DB.Raw(query, list.Title, list.Description, list.Visibility, list.Id, list.Version).Scan(&list.Version)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (m *MovieListModel) Delete(list *MovieList) error {
+	query := m.DB.Table("movie_lists").Where("id = ? AND version = ?", list.Id, list.Version).Delete(&MovieList{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// GetAll browses the lists filtered by title, the public lists when ownerId is 0 otherwise every list of the owner
+// whatever its visibility
+func (m *MovieListModel) GetAll(ownerId int64, title string, filters Filters) ([]*MovieList, Metadata, error) {
+	var listLists []struct {
+		Count int
+		*MovieList
+	}
+	q := m.DB.Table("movie_lists")
+	if ownerId == 0 {
+		q = q.Where("visibility = ?", ListPublic)
+	} else {
+		q = q.Where("user_id = ?", ownerId)
+	}
+	q = q.
+		Where("(to_tsvector('simple', title) @@ plainto_tsquery('simple', @title) OR @title = '')",
+			map[string]interface{}{"title": title}).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, movie_lists.*, " +
+			"(SELECT count(*) FROM movie_list_items WHERE list_id = movie_lists.id) AS item_count").
+		Find(&listLists)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	lists := []*MovieList{}
+	if len(listLists) == 0 {
+		return lists, Metadata{}, nil
+	}
+	for _, item := range listLists {
+		lists = append(lists, item.MovieList)
+	}
+	metadata := calculateMetadata(listLists[0].Count, filters.Page, filters.PageSize)
+	return lists, metadata, nil
+}
+
+// GetItems returns the movies of the list in order
+func (m *MovieListModel) GetItems(listId int64) ([]*MovieListItem, error) {
+	items := []*MovieListItem{}
+	query := m.DB.Table("movie_list_items").Where("list_id = ?", listId).Order("position ASC").Find(&items)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return items, nil
+}
+
+// AddItem appends the movie at the end of the list, it fails with ErrEditConflict when the list
+// changed since it was read
+func (m *MovieListModel) AddItem(list *MovieList, item *MovieListItem) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := bumpListVersion(tx, list)
+		if err != nil {
+			return err
+		}
+
+		query := tx.Raw("SELECT coalesce(max(position), 0) + 1 FROM movie_list_items WHERE list_id = ?", list.Id).
+			Scan(&item.Position)
+		if query.Error != nil {
+			return query.Error
+		}
+
+		item.ListId = list.Id
+		query = tx.Table("movie_list_items").Create(item)
+		if query.Error != nil {
+			switch {
+			case query.Error.Error() == `ERROR: duplicate key value violates unique constraint "movie_list_items_pkey" (SQLSTATE 23505)`:
+				return ErrDuplicateListItem
+			default:
+				return query.Error
+			}
+		}
+		list.ItemCount++
+		return nil
+	})
+}
+
+// RemoveItem takes the movie off the list and closes the gap it leaves in the positions
+func (m *MovieListModel) RemoveItem(list *MovieList, movieId int64) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := bumpListVersion(tx, list)
+		if err != nil {
+			return err
+		}
+
+		var position int32
+		query := tx.Raw("DELETE FROM movie_list_items WHERE list_id = ? AND movie_id = ? RETURNING position", list.Id, movieId).
+			Scan(&position)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+
+		list.ItemCount--
+		return tx.Exec("UPDATE movie_list_items SET position = position - 1 WHERE list_id = ? AND position > ?", list.Id, position).Error
+	})
+}
+
+// Reorder gives the movies of the list the order of movieIds, which must hold every movie of the list exactly once
+func (m *MovieListModel) Reorder(list *MovieList, movieIds []int64) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := bumpListVersion(tx, list)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE movie_list_items
+			SET position = ordered.position
+			FROM unnest(?::bigint[]) WITH ORDINALITY AS ordered(movie_id, position)
+			WHERE movie_list_items.list_id = ? AND movie_list_items.movie_id = ordered.movie_id`
+
+		return tx.Exec(query, pq.Array(movieIds), list.Id).Error
+	})
+}
+
+// bumpListVersion claims the list for a change of its items, so two clients working from the same
+// version can't clobber each other's order
+func bumpListVersion(tx *gorm.DB, list *MovieList) error {
+	query := tx.Raw("UPDATE movie_lists SET version = version + 1 WHERE id = ? AND version = ? RETURNING version", list.Id, list.Version).
+		Scan(&list.Version)
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// generateListSlug turns "Best 90s thrillers" into "best-90s-thrillers-" followed by a random suffix
+func generateListSlug(title string) (string, error) {
+	slug := strings.Trim(listSlugRX.ReplaceAllString(normalizeTitle(title), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = "list"
+	}
+
+	randomBytes := make([]byte, 5)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	suffix := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+	return slug + "-" + suffix, nil
+}
+
+type MovieList struct {
+	Id          int64            `json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UserId      int64            `json:"user_id"`
+	Slug        string           `json:"slug"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Visibility  string           `json:"visibility"`
+	ItemCount   int32            `json:"item_count" gorm:"->"`
+	Version     int32            `json:"version"`
+	Items       []*MovieListItem `json:"items,omitempty" gorm:"-"`
+}
+
+// VisibleTo reports whether the user can see the list, unlisted lists are visible to anyone who has the slug
+func (l *MovieList) VisibleTo(user *User) bool {
+	return l.Visibility != ListPrivate || l.UserId == user.Id
+}
+
+type MovieListItem struct {
+	ListId   int64     `json:"-"`
+	MovieId  int64     `json:"movie_id"`
+	AddedAt  time.Time `json:"added_at" gorm:"autoCreateTime"`
+	Position int32     `json:"position"`
+	Movie    *Movie    `json:"movie,omitempty" gorm:"-"`
+}
+
+func ValidateMovieList(v *validator.Validator, list *MovieList) {
+	v.Check(list.Title != "", "title", "must be provided")
+	v.Check(len(list.Title) <= 200, "title", "must not be more than 200 bytes long")
+
+	v.Check(len(list.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+
+	v.Check(validator.In(list.Visibility, ListPrivate, ListUnlisted, ListPublic), "visibility", "must be private, unlisted or public")
+}
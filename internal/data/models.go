@@ -11,13 +11,42 @@ var (
 )
 
 type Models struct {
-	Movies MovieModel
-	User   UserModel
+	Movies      MovieModel
+	User        UserModel
+	People      PersonModel
+	Credits     CreditModel
+	Genres      GenreModel
+	Tokens      TokenModel
+	Reviews     ReviewModel
+	Watchlist   WatchlistModel
+	Watched     WatchedModel
+	Lists       MovieListModel
+	Collections CollectionModel
+	ExternalIds ExternalIdModel
+	Permissions PermissionModel
+	Edits       MovieEditModel
+	Changes     MovieChangeModel
+	Webhooks    WebhookModel
 }
 
 func NewModels(db *gorm.DB) Models {
+	suggestions := newSuggestionCache(suggestCacheSize)
 	return Models{
-		Movies: MovieModel{db},
-		User:   UserModel{db},
+		Movies:      MovieModel{DB: db, suggestions: suggestions},
+		User:        UserModel{DB: db},
+		People:      PersonModel{DB: db},
+		Credits:     CreditModel{DB: db},
+		Genres:      GenreModel{DB: db},
+		Tokens:      TokenModel{DB: db},
+		Reviews:     ReviewModel{DB: db, suggestions: suggestions},
+		Watchlist:   WatchlistModel{DB: db},
+		Watched:     WatchedModel{DB: db},
+		Lists:       MovieListModel{DB: db},
+		Collections: CollectionModel{DB: db},
+		ExternalIds: ExternalIdModel{DB: db},
+		Permissions: PermissionModel{DB: db},
+		Edits:       MovieEditModel{DB: db},
+		Changes:     MovieChangeModel{DB: db},
+		Webhooks:    WebhookModel{DB: db},
 	}
 }
@@ -0,0 +1,169 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	EditPending  = "pending"
+	EditAccepted = "accepted"
+	EditRejected = "rejected"
+)
+
+var (
+	ErrMovieChanged = errors.New("movie changed since the edit")
+)
+
+var EditStatuses = []string{EditPending, EditAccepted, EditRejected}
+
+type MovieEditModel struct {
+	DB *gorm.DB
+}
+
+func (m *MovieEditModel) Insert(edit *MovieEdit) error {
+	query := `
+		INSERT INTO movie_edits (movie_id, user_id, base_version, patch, comment)
+		VALUES (?, ?, ?, ?::jsonb, ?)
+		RETURNING id, created_at, status, version`
+
+	return m.DB.Raw(query, edit.MovieId, edit.UserId, edit.BaseVersion, string(edit.Patch), edit.Comment).Scan(edit).Error
+}
+
+func (m *MovieEditModel) Get(id int64) (*MovieEdit, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var edit MovieEdit
+	query := m.DB.Table("movie_edits").
+		Select("movie_edits.*, users.name AS user_name").
+		Joins("INNER JOIN users ON users.id = movie_edits.user_id").
+		Where("movie_edits.id = ?", id).
+		Find(&edit)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &edit, nil
+}
+
+// GetAll returns the suggested edits in the given status, optionally restricted to a movie
+func (m *MovieEditModel) GetAll(status string, movieId int64, filters Filters) ([]*MovieEdit, Metadata, error) {
+	var listEdits []struct {
+		Count int
+		*MovieEdit
+	}
+	q := m.DB.Table("movie_edits").
+		Where("status = ?", status).
+		Where("(movie_id = @movie_id OR @movie_id = 0)", map[string]interface{}{"movie_id": movieId}).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, movie_edits.*, (SELECT name FROM users WHERE users.id = movie_edits.user_id) AS user_name").
+		Find(&listEdits)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	edits := []*MovieEdit{}
+	if len(listEdits) == 0 {
+		return edits, Metadata{}, nil
+	}
+	for _, item := range listEdits {
+		edits = append(edits, item.MovieEdit)
+	}
+	metadata := calculateMetadata(listEdits[0].Count, filters.Page, filters.PageSize)
+	return edits, metadata, nil
+}
+
+// Resolve records the decision of a moderator on a pending edit, it fails with ErrEditConflict when
+// the edit was resolved in the meantime
+func (m *MovieEditModel) Resolve(edit *MovieEdit, status string, reviewerId int64, reason string) error {
+	query := `
+		UPDATE movie_edits
+		SET status = ?, reviewed_by = ?, reviewed_at = NOW(), reason = ?, version = version + 1
+		WHERE id = ? AND version = ? AND status = 'pending'
+		RETURNING reviewed_at, version`
+
+	tx := m.DB.Raw(query, status, reviewerId, reason, edit.Id, edit.Version).Scan(edit)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	edit.Status = status
+	edit.ReviewedBy = &reviewerId
+	edit.Reason = reason
+	return nil
+}
+
+// AcceptEdit resolves the pending edit as accepted by the reviewer and applies it to the movie in a single
+// transaction. The movie is updated against the version the edit was suggested against, ErrEditConflict is
+// returned without changing anything when the edit is no longer pending and ErrMovieChanged when the movie
+// moved on since
+func (m *MovieModel) AcceptEdit(edit *MovieEdit, movie *Movie, reviewerId int64) error {
+	query := `
+		UPDATE movie_edits
+		SET status = ?, reviewed_by = ?, reviewed_at = NOW(), reason = '', version = version + 1
+		WHERE id = ? AND version = ? AND status = 'pending'
+		RETURNING reviewed_at, version`
+
+	resolved := *edit
+	movie.Version = edit.BaseVersion
+	movie.UpdatedBy = &reviewerId
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Raw(query, EditAccepted, reviewerId, edit.Id, edit.Version).Scan(&resolved)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		err := updateMovie(tx, movie)
+		if errors.Is(err, ErrEditConflict) {
+			return ErrMovieChanged
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	*edit = resolved
+	edit.Status = EditAccepted
+	edit.ReviewedBy = &reviewerId
+	edit.Reason = ""
+	m.suggestions.invalidate(movie.Id, movie.Title)
+	return nil
+}
+
+// MovieEdit is a correction proposed by a contributor as a JSON Merge Patch against a version of the movie
+type MovieEdit struct {
+	Id          int64           `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	MovieId     int64           `json:"movie_id"`
+	UserId      int64           `json:"user_id"`
+	UserName    string          `json:"user_name,omitempty" gorm:"->"`
+	BaseVersion int32           `json:"base_version"`
+	Patch       json.RawMessage `json:"patch"`
+	Comment     string          `json:"comment,omitempty"`
+	Status      string          `json:"status"`
+	ReviewedBy  *int64          `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time      `json:"reviewed_at,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	Version     int32           `json:"version"`
+}
+
+func ValidateMovieEdit(v *validator.Validator, edit *MovieEdit) {
+	v.Check(edit.BaseVersion > 0, "base_version", "must be provided")
+	v.Check(len(edit.Patch) > 0, "patch", "must be provided")
+	v.Check(len(edit.Comment) <= 1000, "comment", "must not be more than 1000 bytes long")
+}
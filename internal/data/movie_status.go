@@ -0,0 +1,109 @@
+package data
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	MovieDraft     = "draft"
+	MovieInReview  = "in_review"
+	MoviePublished = "published"
+	MovieArchived  = "archived"
+)
+
+var MovieStatuses = []string{MovieDraft, MovieInReview, MoviePublished, MovieArchived}
+
+// publicMovieCondition matches the movies the public can see: published and within their publishing window
+const publicMovieCondition = "status = 'published' AND (publish_at IS NULL OR publish_at <= NOW()) AND (unpublish_at IS NULL OR unpublish_at > NOW())"
+
+var (
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// movieTransitions is the editorial state machine, each allowed transition maps to the permission it requires.
+// Movies go draft -> in_review -> published -> archived, reviewers can send a movie back to draft and
+// archived movies can be reworked as drafts
+var movieTransitions = map[string]map[string]string{
+	MovieDraft: {
+		MovieInReview: PermissionMoviesWrite,
+	},
+	MovieInReview: {
+		MovieDraft:     PermissionMoviesPublish,
+		MoviePublished: PermissionMoviesPublish,
+	},
+	MoviePublished: {
+		MovieArchived: PermissionMoviesPublish,
+	},
+	MovieArchived: {
+		MovieDraft: PermissionMoviesPublish,
+	},
+}
+
+// TransitionPermission returns the permission needed to move a movie from one status to another,
+// ErrInvalidTransition is returned when the state machine doesn't allow it
+func TransitionPermission(from, to string) (string, error) {
+	permission, ok := movieTransitions[from][to]
+	if !ok {
+		return "", ErrInvalidTransition
+	}
+	return permission, nil
+}
+
+// Transition moves the movie to the given status as long as the state machine allows it and the movie
+// is still at the version it was read at
+func (m *MovieModel) Transition(movie *Movie, status string) error {
+	_, err := TransitionPermission(movie.Status, status)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE movies
+		SET status = ?, publish_pending = (? = 'published' AND COALESCE(publish_at > NOW(), false)), version = version + 1
+		WHERE id = ? AND version = ? AND status = ?
+		RETURNING version`
+
+	err = m.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Raw(query, status, status, movie.Id, movie.Version, movie.Status).Scan(&movie.Version)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+		return recordMovieChanges(tx, ChangeUpdated, movie)
+	})
+	if err != nil {
+		return err
+	}
+	movie.Status = status
+	m.suggestions.invalidate(movie.Id, movie.Title)
+	return nil
+}
+
+// GetPublic fetches a movie the public can see, movies which aren't published or are outside of their
+// publishing window are reported as ErrRecordNotFound
+func (m *MovieModel) GetPublic(id int64, columns ...string) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var movie Movie
+	query := m.DB.Table("movies").Select(selectColumns(columns)).Where(publicMovieCondition).Find(&movie, id)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &movie, nil
+}
+
+// IsPublic reports whether the public can see the movie at the given time, it mirrors publicMovieCondition
+func (movie *Movie) IsPublic(now time.Time) bool {
+	return movie.Status == MoviePublished &&
+		(movie.PublishAt == nil || !movie.PublishAt.After(now)) &&
+		(movie.UnpublishAt == nil || movie.UnpublishAt.After(now))
+}
@@ -5,24 +5,38 @@ import (
 	"github.com/duongbm/greenlight-gin/internal/validator"
 	pq "github.com/lib/pq"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
 type MovieModel struct {
-	DB *gorm.DB
+	DB          *gorm.DB
+	suggestions *suggestionCache
 }
 
 func (m *MovieModel) Insert(movie *Movie) error {
-	return m.DB.Table("movies").Create(movie).Error
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("movies").Create(movie).Error
+		if err != nil {
+			return err
+		}
+		return recordMovieChanges(tx, ChangeCreated, movie)
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(movie.Id, movie.Title)
+	return nil
 }
 
-func (m *MovieModel) Get(id int64) (*Movie, error) {
+// Get fetches a movie by id, only the given columns are selected when any are provided
+func (m *MovieModel) Get(id int64, columns ...string) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	var movie Movie
-	query := m.DB.Table("movies").Find(&movie, id)
+	query := m.DB.Table("movies").Select(selectColumns(columns)).Find(&movie, id)
 	if query.RowsAffected == 0 {
 		return nil, ErrRecordNotFound
 	}
@@ -32,64 +46,172 @@ func (m *MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
+// GetByIds fetches the movies with the given ids in a single query, missing ids are skipped
+func (m *MovieModel) GetByIds(ids []int64, columns ...string) ([]*Movie, error) {
+	var movies []*Movie
+	query := m.DB.Table("movies").
+		Select(selectColumns(columns)).
+		Where("id = ANY(?)", pq.Array(ids)).
+		Find(&movies)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return movies, nil
+}
+
 func (m *MovieModel) Update(movie *Movie) error {
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		return updateMovie(tx, movie)
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(movie.Id, movie.Title)
+	return nil
+}
+
+// updateMovie saves the movie as long as it is still at its version and records the change, like
+// recordMovieChanges it must be the last statement of the transaction
+func updateMovie(tx *gorm.DB, movie *Movie) error {
 	query := `
 		UPDATE movies
-		SET title = ?, year = ?, runtime = ?, genres = ? , version = version + 1
+		SET title = ?, year = ?, runtime = ?, genres = ? , updated_by = ?, version = version + 1
 		WHERE id = ? AND version = ?
 		RETURNING version`
 
-	tx := m.DB.Raw(query, movie.Title, movie.Year, movie.Runtime, movie.Genres, movie.Id, movie.Version).Scan(&movie)
-	if tx.RowsAffected == 0 {
-		return ErrRecordNotFound
+	result := tx.Raw(query, movie.Title, movie.Year, movie.Runtime, movie.Genres, movie.UpdatedBy, movie.Id, movie.Version).Scan(&movie)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEditConflict
 	}
-	return tx.Error
+	return recordMovieChanges(tx, ChangeUpdated, movie)
 }
 
-func (m *MovieModel) Delete(id int64) error {
-	query := m.DB.Table("movies").Where("id = ?", id).Delete(&Movie{})
-	if query.RowsAffected == 0 {
-		return ErrRecordNotFound
+// Delete removes the movie as long as it is still at the given version
+func (m *MovieModel) Delete(movie *Movie) error {
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("movies").Where("id = ? AND version = ?", movie.Id, movie.Version).Delete(&Movie{})
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+		return recordMovieChanges(tx, ChangeDeleted, movie)
+	})
+	if err != nil {
+		return err
 	}
-	return query.Error
+	m.suggestions.invalidate(movie.Id, "")
+	return nil
+}
+
+// MovieSearch holds the criteria movie lists are filtered by
+type MovieSearch struct {
+	// Public restricts the search to the movies the public can see, see MovieModel.GetPublic
+	Public          bool
+	Title           string
+	Genres          []string
+	PersonId        int64
+	CreatedBy       int64
+	CollectionId    int64
+	HasExternal     []string
+	MissingExternal []string
+	Statuses        []string
 }
 
-func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// scope filters a query on the movies table by the search criteria
+func (s MovieSearch) scope(db *gorm.DB) *gorm.DB {
+	db = db.Where(`
+			(to_tsvector('simple', title) @@ plainto_tsquery('simple', @title) OR @title = '') 
+			AND (genres @> @genres OR @genres = '{}')`,
+		map[string]interface{}{"title": s.Title, "genres": pq.Array(s.Genres)})
+	if s.PersonId != 0 {
+		db = db.Where("id IN (SELECT movie_id FROM movie_credits WHERE person_id = ?)", s.PersonId)
+	}
+	if s.CreatedBy != 0 {
+		db = db.Where("created_by = ?", s.CreatedBy)
+	}
+	if s.CollectionId != 0 {
+		db = db.Where("id IN (SELECT movie_id FROM collection_movies WHERE collection_id = ?)", s.CollectionId)
+	}
+	if s.Public {
+		db = db.Where(publicMovieCondition)
+	}
+	if len(s.Statuses) > 0 {
+		db = db.Where("status = ANY(?)", pq.Array(s.Statuses))
+	}
+	for _, source := range s.HasExternal {
+		db = db.Where("id IN (SELECT movie_id FROM movie_external_ids WHERE source = ?)", source)
+	}
+	for _, source := range s.MissingExternal {
+		db = db.Where("id NOT IN (SELECT movie_id FROM movie_external_ids WHERE source = ?)", source)
+	}
+	return db
+}
+
+func ValidateMovieSearch(v *validator.Validator, s MovieSearch) {
+	for _, source := range s.HasExternal {
+		v.Check(validator.In(source, ExternalSources...), "has_external", fmt.Sprintf("unknown external source %q", source))
+	}
+	for _, status := range s.Statuses {
+		v.Check(validator.In(status, MovieStatuses...), "status", fmt.Sprintf("unknown status %q", status))
+	}
+	for _, source := range s.MissingExternal {
+		v.Check(validator.In(source, ExternalSources...), "missing_external", fmt.Sprintf("unknown external source %q", source))
+	}
+}
+
+// movieIds returns a subquery selecting the ids of the movies matching the search criteria
+func (s MovieSearch) movieIds(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table("movies").Select("id").Scopes(s.scope)
+}
+
+func (m *MovieModel) GetAll(search MovieSearch, filters Filters, columns ...string) ([]*Movie, Metadata, error) {
 	var movies []*Movie
 	var listMovies []struct {
 		Count int
 		*Movie
 	}
 	q := m.DB.Debug().Model(&Movie{}).
-		Where(`
-			(to_tsvector('simple', title) @@ plainto_tsquery('simple', @title) OR @title = '') 
-			AND (genres @> @genres OR @genres = '{}')`,
-			map[string]interface{}{"title": title, "genres": pq.Array(genres)}).
-		Order(fmt.Sprintf("%s %s,id ASC", filters.sortColum(), filters.sortDirection())).
+		Scopes(search.scope).
+		Order(filters.orderBy()).
 		Limit(filters.limit()).
 		Offset(filters.offset()).
-		Select("count(*) OVER() as count, *").
+		Select("count(*) OVER() as count, " + strings.Join(selectColumns(columns), ", ")).
 		Find(&listMovies)
 
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
 	if q.RowsAffected == 0 {
-		return nil, Metadata{}, ErrRecordNotFound
+		return []*Movie{}, Metadata{}, nil
 	}
 
 	for _, item := range listMovies {
 		movies = append(movies, item.Movie)
 	}
 	metadata := calculateMetadata(listMovies[0].Count, filters.Page, filters.PageSize)
-	return movies, metadata, q.Error
+	return movies, metadata, nil
 }
 
 type Movie struct {
-	Id        int64          `json:"id"`
-	CreatedAt time.Time      `json:"-"`
-	Title     string         `json:"title"`
-	Year      int32          `json:"year,omitempty"`
-	Runtime   Runtime        `json:"runtime,omitempty"`
-	Genres    pq.StringArray `json:"genres,omitempty" gorm:"type:text[]"`
-	Version   int32          `json:"version"`
+	Id            int64          `json:"id"`
+	CreatedAt     time.Time      `json:"-"`
+	Title         string         `json:"title"`
+	Year          int32          `json:"year,omitempty"`
+	Runtime       Runtime        `json:"runtime,omitempty"`
+	Genres        pq.StringArray `json:"genres,omitempty" gorm:"type:text[]"`
+	RatingAverage float64        `json:"rating_average"`
+	RatingCount   int32          `json:"rating_count"`
+	Status        string         `json:"status"`
+	PublishAt     *time.Time     `json:"publish_at,omitempty"`
+	UnpublishAt   *time.Time     `json:"unpublish_at,omitempty"`
+	CreatedBy     *int64         `json:"created_by,omitempty"`
+	UpdatedBy     *int64         `json:"updated_by,omitempty"`
+	Version       int32          `json:"version"`
 }
 
 func ValidateMovie(v *validator.Validator, movie *Movie) {
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"time"
+)
+
+type PersonModel struct {
+	DB *gorm.DB
+}
+
+func (m *PersonModel) Insert(person *Person) error {
+	return m.DB.Table("people").Create(person).Error
+}
+
+func (m *PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var person Person
+	query := m.DB.Table("people").Find(&person, id)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &person, nil
+}
+
+// GetByIds fetches the people with the given ids in a single query, missing ids are skipped
+func (m *PersonModel) GetByIds(ids []int64) ([]*Person, error) {
+	var people []*Person
+	query := m.DB.Table("people").Where("id = ANY(?)", pq.Array(ids)).Find(&people)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return people, nil
+}
+
+func (m *PersonModel) Update(person *Person) error {
+	query := `
+		UPDATE people
+		SET name = ?, birth_year = ?, version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version`
+
+	tx := m.DB.Raw(query, person.Name, person.BirthYear, person.Id, person.Version).Scan(&person)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// Delete removes the person as long as it is still at the given version, their credits go with them
+func (m *PersonModel) Delete(person *Person) error {
+	query := m.DB.Table("people").Where("id = ? AND version = ?", person.Id, person.Version).Delete(&Person{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (m *PersonModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	var listPeople []struct {
+		Count int
+		*Person
+	}
+	q := m.DB.Table("people").
+		Where(`to_tsvector('simple', name) @@ plainto_tsquery('simple', @name) OR @name = ''`,
+			map[string]interface{}{"name": name}).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, *").
+		Find(&listPeople)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	people := []*Person{}
+	if len(listPeople) == 0 {
+		return people, Metadata{}, nil
+	}
+	for _, item := range listPeople {
+		people = append(people, item.Person)
+	}
+	metadata := calculateMetadata(listPeople[0].Count, filters.Page, filters.PageSize)
+	return people, metadata, nil
+}
+
+type Person struct {
+	Id        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	BirthYear int32     `json:"birth_year,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if person.BirthYear != 0 {
+		v.Check(person.BirthYear >= 1800, "birth_year", "must be greater than 1800")
+		v.Check(person.BirthYear <= int32(time.Now().Year()), "birth_year", "must not be in the future")
+	}
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+const (
+	// PermissionMoviesWrite lets editors manage the catalogue and see unpublished movies
+	PermissionMoviesWrite = "movies:write"
+	// PermissionMoviesPublish lets editors publish, send back and archive movies
+	PermissionMoviesPublish = "movies:publish"
+	// PermissionMoviesAdmin lets editors modify and delete movies they don't own
+	PermissionMoviesAdmin = "movies:admin"
+	// PermissionWebhooksWrite lets partners subscribe webhooks to catalogue events
+	PermissionWebhooksWrite = "webhooks:write"
+)
+
+// Permissions holds the permission codes of a user, e.g. "movies:write"
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+type PermissionModel struct {
+	DB *gorm.DB
+}
+
+func (m *PermissionModel) GetAllForUser(userId int64) (Permissions, error) {
+	permissions := Permissions{}
+	query := m.DB.Table("permissions").
+		Joins("INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id").
+		Where("users_permissions.user_id = ?", userId).
+		Order("permissions.code").
+		Pluck("permissions.code", &permissions)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return permissions, nil
+}
+
+// AddForUser grants the permissions to the user, unknown codes are ignored and granting twice is a no-op
+func (m *PermissionModel) AddForUser(userId int64, codes ...string) error {
+	query := `
+		INSERT INTO users_permissions (user_id, permission_id)
+		SELECT ?, permissions.id FROM permissions WHERE permissions.code = ANY(?)
+		ON CONFLICT DO NOTHING`
+
+	return m.DB.Exec(query, userId, pq.Array(codes)).Error
+}
@@ -0,0 +1,10 @@
+package data
+
+// CanModifyMovie reports whether the user may edit or delete the movie, owners can modify the movies they
+// created and admins can modify any movie
+func CanModifyMovie(user *User, permissions Permissions, movie *Movie) bool {
+	if permissions.Include(PermissionMoviesAdmin) {
+		return true
+	}
+	return !user.IsAnonymous() && movie.CreatedBy != nil && *movie.CreatedBy == user.Id
+}
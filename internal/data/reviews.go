@@ -0,0 +1,244 @@
+package data
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrDuplicateReview = errors.New("duplicate review")
+)
+
+type ReviewModel struct {
+	DB *gorm.DB
+	// suggestions is the cache of the movie model, suggestions are ranked by the number of ratings
+	suggestions *suggestionCache
+}
+
+// Insert stores the review and refreshes the movie rating aggregate in the same transaction, see refreshMovieRating
+func (m *ReviewModel) Insert(review *Review) error {
+	var rated *Movie
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockMovie(tx, review.MovieId)
+		if err != nil {
+			return err
+		}
+
+		query := tx.Table("reviews").Create(review)
+		if query.Error != nil {
+			switch {
+			case query.Error.Error() == `ERROR: duplicate key value violates unique constraint "reviews_user_id_movie_id_key" (SQLSTATE 23505)`:
+				return ErrDuplicateReview
+			default:
+				return query.Error
+			}
+		}
+
+		rated, err = recordMovieRating(tx, review.MovieId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(rated.Id, rated.Title)
+	return nil
+}
+
+func (m *ReviewModel) Get(movieId, id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var review Review
+	query := m.DB.Table("reviews").
+		Select("reviews.*, users.name AS user_name").
+		Joins("INNER JOIN users ON users.id = reviews.user_id").
+		Where("reviews.id = ? AND reviews.movie_id = ?", id, movieId).
+		Find(&review)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &review, nil
+}
+
+func (m *ReviewModel) Update(review *Review) error {
+	var rated *Movie
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockMovie(tx, review.MovieId)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE reviews
+			SET rating = ?, body = ?, version = version + 1
+			WHERE id = ? AND version = ?
+			RETURNING version`
+
+		result := tx.Raw(query, review.Rating, review.Body, review.Id, review.Version).Scan(&review)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		rated, err = recordMovieRating(tx, review.MovieId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(rated.Id, rated.Title)
+	return nil
+}
+
+func (m *ReviewModel) Delete(review *Review) error {
+	var rated *Movie
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockMovie(tx, review.MovieId)
+		if err != nil {
+			return err
+		}
+
+		query := tx.Table("reviews").Where("id = ? AND version = ?", review.Id, review.Version).Delete(&Review{})
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		rated, err = recordMovieRating(tx, review.MovieId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(rated.Id, rated.Title)
+	return nil
+}
+
+func (m *ReviewModel) GetAllForMovie(movieId int64, filters Filters) ([]*Review, Metadata, error) {
+	var listReviews []struct {
+		Count int
+		*Review
+	}
+	q := m.DB.Table("reviews").
+		Where("movie_id = ?", movieId).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, reviews.*, (SELECT name FROM users WHERE users.id = reviews.user_id) AS user_name").
+		Find(&listReviews)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	reviews := []*Review{}
+	if len(listReviews) == 0 {
+		return reviews, Metadata{}, nil
+	}
+	for _, item := range listReviews {
+		reviews = append(reviews, item.Review)
+	}
+	metadata := calculateMetadata(listReviews[0].Count, filters.Page, filters.PageSize)
+	return reviews, metadata, nil
+}
+
+// GetForMovies returns the latest reviews of each movie, at most limit per movie
+func (m *ReviewModel) GetForMovies(movieIds []int64, limit int) (map[int64][]*Review, error) {
+	query := `
+		SELECT * FROM (
+			SELECT reviews.*, users.name AS user_name,
+				row_number() OVER (PARTITION BY reviews.movie_id ORDER BY reviews.created_at DESC, reviews.id DESC) AS rank
+			FROM reviews
+			INNER JOIN users ON users.id = reviews.user_id
+			WHERE reviews.movie_id = ANY(?)
+		) AS ranked
+		WHERE rank <= ?
+		ORDER BY movie_id, rank`
+
+	var reviews []*Review
+	err := m.DB.Raw(query, pq.Array(movieIds), limit).Scan(&reviews).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reviewsByMovie := make(map[int64][]*Review, len(movieIds))
+	for _, id := range movieIds {
+		reviewsByMovie[id] = []*Review{}
+	}
+	for _, review := range reviews {
+		reviewsByMovie[review.MovieId] = append(reviewsByMovie[review.MovieId], review)
+	}
+	return reviewsByMovie, nil
+}
+
+// lockMovie serializes rating changes of a movie so the aggregate never misses a concurrent review
+func lockMovie(tx *gorm.DB, movieId int64) error {
+	var id int64
+	query := tx.Raw("SELECT id FROM movies WHERE id = ? FOR UPDATE", movieId).Scan(&id)
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// refreshMovieRating recomputes the rating aggregate of a movie. The rating is part of the movie so the movie
+// gets a new version, the caller records the returned movie in the change feed
+func refreshMovieRating(tx *gorm.DB, movieId int64) (*Movie, error) {
+	query := `
+		UPDATE movies
+		SET rating_average = stats.average, rating_count = stats.count, version = version + 1
+		FROM (SELECT coalesce(avg(rating), 0) AS average, count(*) AS count FROM reviews WHERE movie_id = ?) AS stats
+		WHERE movies.id = ?
+		RETURNING movies.id, movies.title, movies.version`
+
+	var movie Movie
+	result := tx.Raw(query, movieId, movieId).Scan(&movie)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &movie, nil
+}
+
+// recordMovieRating refreshes the rating aggregate of the movie and records the change, like
+// recordMovieChanges it must be the last statement of the transaction
+func recordMovieRating(tx *gorm.DB, movieId int64) (*Movie, error) {
+	movie, err := refreshMovieRating(tx, movieId)
+	if err != nil {
+		return nil, err
+	}
+	return movie, recordMovieChanges(tx, ChangeUpdated, movie)
+}
+
+type Review struct {
+	Id        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	MovieId   int64     `json:"movie_id"`
+	UserId    int64     `json:"user_id"`
+	UserName  string    `json:"user_name,omitempty" gorm:"->"`
+	Rating    int32     `json:"rating"`
+	Body      string    `json:"body,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating >= 1, "rating", "must be at least 1")
+	v.Check(review.Rating <= 10, "rating", "must not be more than 10")
+
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
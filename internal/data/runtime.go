@@ -1,37 +1,146 @@
 package data
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+var (
+	runtimeMinsRX    = regexp.MustCompile(`^(\d+) mins$`)
+	runtimeISO8601RX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+	runtimeHoursRX   = regexp.MustCompile(`^(?:(\d+)h)? ?(?:(\d+)m)?$`)
+)
+
+const (
+	RuntimeFormatMins    = "mins"
+	RuntimeFormatISO8601 = "iso8601"
+	RuntimeFormatSeconds = "seconds"
+)
+
+var RuntimeFormats = []string{RuntimeFormatMins, RuntimeFormatISO8601, RuntimeFormatSeconds}
+
+// Runtime is a movie runtime in minutes
 type Runtime int32
 
-func (r *Runtime) MarshalJSON() ([]byte, error) {
+func (r Runtime) MarshalJSON() ([]byte, error) {
 	jsonValue := fmt.Sprintf("%d mins", r)
 
 	quoteJSONValue := strconv.Quote(jsonValue)
 	return []byte(quoteJSONValue), nil
 }
 
+// UnmarshalJSON accepts "<n> mins", ISO 8601 durations such as "PT2H14M",
+// hours and minutes such as "2h 14m" and plain integers of minutes
 func (r *Runtime) UnmarshalJSON(b []byte) error {
+	if i, err := strconv.ParseInt(string(b), 10, 32); err == nil {
+		*r = Runtime(i)
+		return nil
+	}
+
 	unquotedValue, err := strconv.Unquote(string(b))
 	if err != nil {
-		return ErrInvalidRuntimeFormat
-	}
-	parts := strings.Split(unquotedValue, " ")
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFormat
+		return fmt.Errorf("%w: must be a string or an integer number of minutes", ErrInvalidRuntimeFormat)
 	}
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+
+	runtime, err := parseRuntime(unquotedValue)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	*r = Runtime(i)
+	*r = runtime
 	return nil
 }
+
+func parseRuntime(s string) (Runtime, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, fmt.Errorf("%w: must not be blank", ErrInvalidRuntimeFormat)
+	}
+
+	var hours, minutes, seconds string
+	if m := runtimeMinsRX.FindStringSubmatch(s); m != nil {
+		minutes = m[1]
+	} else if m := runtimeISO8601RX.FindStringSubmatch(s); m != nil && s != "PT" {
+		hours, minutes, seconds = m[1], m[2], m[3]
+	} else if m := runtimeHoursRX.FindStringSubmatch(s); m != nil && s != "" {
+		hours, minutes = m[1], m[2]
+		if hours != "" && minutes != "" && atoi(minutes) >= 60 {
+			return 0, fmt.Errorf("%w: %q minutes must be less than 60 when hours are given", ErrInvalidRuntimeFormat, s)
+		}
+	} else {
+		return 0, fmt.Errorf(`%w: %q must be "<n> mins", an ISO 8601 duration such as "PT2H14M", hours and minutes such as "2h 14m" or an integer number of minutes`, ErrInvalidRuntimeFormat, s)
+	}
+
+	if atoi(seconds)%60 != 0 {
+		return 0, fmt.Errorf("%w: %q must be a whole number of minutes", ErrInvalidRuntimeFormat, s)
+	}
+
+	total := atoi(hours)*60 + atoi(minutes) + atoi(seconds)/60
+	if total > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %q is too long", ErrInvalidRuntimeFormat, s)
+	}
+	return Runtime(total), nil
+}
+
+// atoi parses a regexp group of digits, an empty group is zero and
+// values out of range are clamped so the total is reported as too long
+func atoi(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || i > math.MaxInt32 {
+		return math.MaxInt32 + 1
+	}
+	return i
+}
+
+// Format returns the runtime as "<n> mins", an ISO 8601 duration or a number of seconds
+func (r Runtime) Format(format string) interface{} {
+	switch format {
+	case RuntimeFormatISO8601:
+		hours, minutes := r/60, r%60
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+	case RuntimeFormatSeconds:
+		return int64(r) * 60
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
+// Scan implements sql.Scanner, runtimes are stored as an integer number of minutes
+func (r *Runtime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*r = Runtime(v)
+	case []byte:
+		i, err := strconv.ParseInt(string(v), 10, 32)
+		if err != nil {
+			return err
+		}
+		*r = Runtime(i)
+	case nil:
+		*r = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Runtime", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer
+func (r Runtime) Value() (driver.Value, error) {
+	return int64(r), nil
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want Runtime
+	}{
+		{"mins", `"102 mins"`, 102},
+		{"zero mins", `"0 mins"`, 0},
+		{"bare integer", `102`, 102},
+		{"iso 8601 hours and minutes", `"PT2H14M"`, 134},
+		{"iso 8601 hours", `"PT2H"`, 120},
+		{"iso 8601 minutes", `"PT90M"`, 90},
+		{"iso 8601 whole minutes of seconds", `"PT1H30M120S"`, 92},
+		{"hours and minutes", `"2h 14m"`, 134},
+		{"hours and minutes without space", `"2h14m"`, 134},
+		{"hours", `"2h"`, 120},
+		{"minutes", `"45m"`, 45},
+		{"minutes over an hour without hours", `"95m"`, 95},
+		// the sign of bare integers is left to ValidateMovie
+		{"negative integer", `-5`, -5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Runtime
+			if err := r.UnmarshalJSON([]byte(tt.json)); err != nil {
+				t.Fatal(err)
+			}
+			if r != tt.want {
+				t.Errorf("runtime = %d, want %d", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeUnmarshalJSONRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"empty string", `""`},
+		{"blank string", `"   "`},
+		{"single space", `" "`},
+		{"tab", `"\t"`},
+		{"surrounding spaces", `" 2h 14m "`},
+		{"unknown unit", `"102 minutes"`},
+		{"negative mins", `"-5 mins"`},
+		{"fractional integer", `1.5`},
+		{"bare integer string", `"102"`},
+		{"empty iso 8601", `"PT"`},
+		{"iso 8601 days", `"P1DT2H"`},
+		{"iso 8601 partial minute", `"PT90S"`},
+		{"minutes over an hour with hours", `"2h 75m"`},
+		{"too long", `"99999999999 mins"`},
+		{"boolean", `true`},
+		{"object", `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r Runtime
+			err := r.UnmarshalJSON([]byte(tt.json))
+			if !errors.Is(err, ErrInvalidRuntimeFormat) {
+				t.Errorf("err = %v, want ErrInvalidRuntimeFormat", err)
+			}
+		})
+	}
+}
+
+func TestRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		format  string
+		want    interface{}
+	}{
+		{134, RuntimeFormatMins, "134 mins"},
+		{134, RuntimeFormatISO8601, "PT2H14M"},
+		{120, RuntimeFormatISO8601, "PT2H"},
+		{45, RuntimeFormatISO8601, "PT45M"},
+		{0, RuntimeFormatISO8601, "PT0M"},
+		{134, RuntimeFormatSeconds, int64(8040)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.runtime.Format(tt.format); got != tt.want {
+			t.Errorf("Format(%d, %s) = %v, want %v", tt.runtime, tt.format, got, tt.want)
+		}
+	}
+}
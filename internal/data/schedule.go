@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"gorm.io/gorm"
+)
+
+// movieScheduleLockKey identifies the advisory lock which keeps the schedule to a single API instance at a time
+const movieScheduleLockKey int64 = 0x676c5f7363686564
+
+// Schedule saves the publishing window of the movie
+func (m *MovieModel) Schedule(movie *Movie) error {
+	query := `
+		UPDATE movies
+		SET publish_at = ?, unpublish_at = ?, publish_pending = (status = 'published' AND COALESCE(?::timestamptz > NOW(), false)),
+			version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version`
+
+	err := m.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Raw(query, movie.PublishAt, movie.UnpublishAt, movie.PublishAt, movie.Id, movie.Version).Scan(&movie.Version)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+		return recordMovieChanges(tx, ChangeUpdated, movie)
+	})
+	if err != nil {
+		return err
+	}
+	m.suggestions.invalidate(movie.Id, movie.Title)
+	return nil
+}
+
+// ScheduleResult counts the movies a run of the schedule moved
+type ScheduleResult struct {
+	Locked    bool
+	Published int
+	Archived  int
+}
+
+// ApplySchedule publishes the movies in review whose publish_at has come and archives the published movies
+// whose unpublish_at has passed. Movies published ahead of their publish_at become public without changing
+// status, they are counted as published and get a new version as well so the change is recorded. Only one
+// API instance applies the schedule at a time, the others get a result which isn't Locked. A run which fails
+// is rolled back as a whole and the next run picks up the same movies, so every transition happens at least
+// once
+func (m *MovieModel) ApplySchedule(ctx context.Context) (ScheduleResult, error) {
+	var result ScheduleResult
+	var changed []*Movie
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", movieScheduleLockKey).Scan(&result.Locked).Error
+		if err != nil || !result.Locked {
+			return err
+		}
+
+		transitions := []struct {
+			from, to, due string
+			count         *int
+		}{
+			{MovieInReview, MoviePublished, "publish_at", &result.Published},
+			{MoviePublished, MovieArchived, "unpublish_at", &result.Archived},
+		}
+		// publish_pending marks the movies published before their publish_at, see Schedule and Transition
+		var released []*Movie
+		query := `
+			UPDATE movies
+			SET publish_pending = false, version = version + 1
+			WHERE publish_pending AND status = 'published' AND publish_at <= NOW()
+			RETURNING id, title, version`
+		err = tx.Raw(query).Scan(&released).Error
+		if err != nil {
+			return err
+		}
+		changed = append(changed, released...)
+
+		for _, transition := range transitions {
+			var moved []*Movie
+			query := `
+				UPDATE movies
+				SET status = ?, version = version + 1
+				WHERE status = ? AND ` + transition.due + ` <= NOW()
+				RETURNING id, title, version`
+
+			err = tx.Raw(query, transition.to, transition.from).Scan(&moved).Error
+			if err != nil {
+				return err
+			}
+			*transition.count = len(moved)
+			changed = append(changed, moved...)
+		}
+		result.Published += len(released)
+		return recordMovieChanges(tx, ChangeUpdated, changed...)
+	})
+	if err != nil {
+		return ScheduleResult{}, err
+	}
+	for _, movie := range changed {
+		m.suggestions.invalidate(movie.Id, movie.Title)
+	}
+	return result, nil
+}
+
+func ValidateMovieSchedule(v *validator.Validator, movie *Movie) {
+	if movie.PublishAt != nil && movie.UnpublishAt != nil {
+		v.Check(movie.UnpublishAt.After(*movie.PublishAt), "unpublish_at", "must be after publish_at")
+	}
+}
@@ -0,0 +1,73 @@
+package data
+
+// SimilarityWeights tunes how much each signal contributes to the similarity score of two movies,
+// every signal is normalized between 0 and 1 before being weighted
+type SimilarityWeights struct {
+	Genres   float64
+	Year     float64
+	Runtime  float64
+	CoRating float64
+}
+
+type SimilarMovie struct {
+	*Movie
+	Score float64 `json:"score"`
+}
+
+// Similar returns the movies most similar to the given one, best score first. Candidates share at least
+// one genre with the movie or were rated alike by the same users, only movies the public can see are returned
+// and never the movie itself.
+//
+// The signals are the Jaccard index of the genres, the year and runtime proximity, and the share of the
+// movie reviewers who gave the candidate a rating within 2 points of the rating they gave the movie
+func (m *MovieModel) Similar(id int64, weights SimilarityWeights, limit int) ([]*SimilarMovie, error) {
+	query := `
+		WITH source AS (
+			SELECT id, year, runtime, genres FROM movies WHERE id = @id
+		), co_ratings AS (
+			SELECT other.movie_id, count(*) AS shared
+			FROM reviews own
+			INNER JOIN reviews other ON other.user_id = own.user_id AND other.movie_id <> own.movie_id
+			WHERE own.movie_id = @id AND abs(own.rating - other.rating) <= 2
+			GROUP BY other.movie_id
+		), reviewers AS (
+			SELECT count(*) AS total FROM reviews WHERE movie_id = @id
+		)
+		SELECT movies.*,
+			@genres * coalesce(
+				cardinality(ARRAY(SELECT unnest(movies.genres) INTERSECT SELECT unnest(source.genres)))::float8
+				/ nullif(cardinality(ARRAY(SELECT unnest(movies.genres) UNION SELECT unnest(source.genres))), 0), 0)
+			+ @year * (1 / (1 + abs(movies.year - source.year) / 10.0))
+			+ @runtime * (1 / (1 + abs(movies.runtime - source.runtime) / 30.0))
+			+ @co_rating * coalesce(co_ratings.shared::float8 / nullif(reviewers.total, 0), 0) AS score
+		FROM movies
+		CROSS JOIN source
+		CROSS JOIN reviewers
+		LEFT JOIN co_ratings ON co_ratings.movie_id = movies.id
+		WHERE movies.id <> source.id AND ` + publicMovieCondition + `
+			AND (movies.genres && source.genres OR co_ratings.movie_id IS NOT NULL)
+		ORDER BY score DESC, movies.id ASC
+		LIMIT @limit`
+
+	var rows []struct {
+		Score float64
+		*Movie
+	}
+	tx := m.DB.Raw(query, map[string]interface{}{
+		"id":        id,
+		"genres":    weights.Genres,
+		"year":      weights.Year,
+		"runtime":   weights.Runtime,
+		"co_rating": weights.CoRating,
+		"limit":     limit,
+	}).Scan(&rows)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	movies := []*SimilarMovie{}
+	for _, row := range rows {
+		movies = append(movies, &SimilarMovie{Movie: row.Movie, Score: row.Score})
+	}
+	return movies, nil
+}
@@ -0,0 +1,164 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// suggestQueryTimeout is the latency budget for a single suggestion query,
+	// search-as-you-type clients prefer no answer over a late one
+	suggestQueryTimeout = 250 * time.Millisecond
+
+	suggestCacheSize = 512
+)
+
+type MovieSuggestion struct {
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+// Suggest returns movies whose title starts with prefix, ignoring case and diacritics,
+// the most rated movies first then the most recent ones. A query over its latency budget returns no
+// suggestions rather than an error
+func (m *MovieModel) Suggest(prefix string, limit int) ([]*MovieSuggestion, error) {
+	key := fmt.Sprintf("%d:%s", limit, normalizeTitle(prefix))
+	suggestions, generation, ok := m.suggestions.get(key)
+	if ok {
+		return suggestions, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), suggestQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, title, year
+		FROM movies
+		WHERE lower(immutable_unaccent(title)) LIKE lower(immutable_unaccent(?)) || '%' AND ` + publicMovieCondition + `
+		ORDER BY rating_count DESC, year DESC, id DESC
+		LIMIT ?`
+
+	suggestions = []*MovieSuggestion{}
+	tx := m.DB.WithContext(ctx).Raw(query, escapeLike(prefix), limit).Scan(&suggestions)
+	if tx.Error != nil {
+		if errors.Is(tx.Error, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return []*MovieSuggestion{}, nil
+		}
+		return nil, tx.Error
+	}
+
+	m.suggestions.add(key, normalizeTitle(prefix), suggestions, generation)
+	return suggestions, nil
+}
+
+// normalizeTitle mirrors lower(unaccent(title)) on the database side
+func normalizeTitle(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, s)
+	if err != nil {
+		result = s
+	}
+	return strings.ToLower(result)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// suggestionCache is a small LRU cache of the hottest prefixes. The generation changes on every invalidation,
+// suggestions read from the database before an invalidation are stale and are not cached
+type suggestionCache struct {
+	mu         sync.Mutex
+	capacity   int
+	generation uint64
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type suggestionEntry struct {
+	key         string
+	prefix      string
+	suggestions []*MovieSuggestion
+}
+
+func newSuggestionCache(capacity int) *suggestionCache {
+	return &suggestionCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached suggestions for key, on a miss it returns the generation to pass to add
+func (c *suggestionCache) get(key string) ([]*MovieSuggestion, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*suggestionEntry).suggestions, c.generation, true
+}
+
+// add caches the suggestions unless the cache was invalidated since generation
+func (c *suggestionCache) add(key, prefix string, suggestions []*MovieSuggestion, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*suggestionEntry).suggestions = suggestions
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&suggestionEntry{key: key, prefix: prefix, suggestions: suggestions})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*suggestionEntry).key)
+	}
+}
+
+// invalidate drops every cached prefix which matches title or which holds the movie with the given id,
+// the latter covers renamed and deleted movies
+func (c *suggestionCache) invalidate(id int64, title string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	title = normalizeTitle(title)
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*suggestionEntry)
+		if strings.HasPrefix(title, entry.prefix) || entry.contains(id) {
+			c.ll.Remove(e)
+			delete(c.items, entry.key)
+		}
+		e = next
+	}
+}
+
+func (e *suggestionEntry) contains(id int64) bool {
+	for _, s := range e.suggestions {
+		if s.Id == id {
+			return true
+		}
+	}
+	return false
+}
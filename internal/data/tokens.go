@@ -0,0 +1,69 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	ScopeAuthentication = "authentication"
+)
+
+type TokenModel struct {
+	DB *gorm.DB
+}
+
+// New generates a token for the user and stores it
+func (m *TokenModel) New(userId int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userId, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m *TokenModel) Insert(token *Token) error {
+	return m.DB.Table("tokens").Create(token).Error
+}
+
+func (m *TokenModel) DeleteAllForUser(scope string, userId int64) error {
+	return m.DB.Table("tokens").Where("scope = ? AND user_id = ?", scope, userId).Delete(&Token{}).Error
+}
+
+type Token struct {
+	Plaintext string    `json:"token" gorm:"-"`
+	Hash      []byte    `json:"-" gorm:"primaryKey"`
+	UserId    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+}
+
+func generateToken(userId int64, ttl time.Duration, scope string) (*Token, error) {
+	token := &Token{
+		UserId: userId,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+	return token, nil
+}
+
+func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
+	v.Check(tokenPlaintext != "", "token", "must be provided")
+	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
+}
@@ -1,8 +1,10 @@
 package data
 
 import (
+	"crypto/sha256"
 	"errors"
 	"github.com/duongbm/greenlight-gin/internal/validator"
+	pq "github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -13,6 +15,9 @@ var (
 	ErrDuplicateEmail = errors.New("duplicate email")
 )
 
+// AnonymousUser represents a request without an authentication token
+var AnonymousUser = &User{}
+
 type UserModel struct {
 	DB *gorm.DB
 }
@@ -31,17 +36,62 @@ func (m *UserModel) Insert(user *User) error {
 	return nil
 }
 
-func (m *UserModel) GetByEmail(email string) (user *User, err error) {
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	var user User
 	query := m.DB.Where("email = ?", email).Find(&user)
 	if query.Error != nil {
-		switch {
-		case errors.Is(query.Error, gorm.ErrRecordNotFound):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, query.Error
-		}
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
 	}
-	return user, nil
+	user.Password.hash = user.PasswordHash
+	return &user, nil
+}
+
+func (m *UserModel) Get(id int64) (*User, error) {
+	var user User
+	query := m.DB.Find(&user, id)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	user.Password.hash = user.PasswordHash
+	return &user, nil
+}
+
+// GetByIds fetches the users with the given ids in a single query, missing ids are skipped
+func (m *UserModel) GetByIds(ids []int64) ([]*User, error) {
+	var users []*User
+	query := m.DB.Where("id = ANY(?)", pq.Array(ids)).Find(&users)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return users, nil
+}
+
+// GetForToken fetches the user owning an unexpired token of the given scope
+func (m *UserModel) GetForToken(scope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT users.*
+		FROM users
+		INNER JOIN tokens ON users.id = tokens.user_id
+		WHERE tokens.hash = ? AND tokens.scope = ? AND tokens.expiry > ?`
+
+	var user User
+	tx := m.DB.Raw(query, tokenHash[:], scope, time.Now()).Scan(&user)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	user.Password.hash = user.PasswordHash
+	return &user, nil
 }
 
 func (m *UserModel) Update(user *User) error {
@@ -70,6 +120,10 @@ type User struct {
 	Version      int       `json:"-"`
 }
 
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
 type password struct {
 	plaintext *string
 	hash      []byte
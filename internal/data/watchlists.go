@@ -0,0 +1,282 @@
+package data
+
+import (
+	"errors"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrDuplicateWatchlistItem = errors.New("duplicate watchlist item")
+)
+
+type WatchlistModel struct {
+	DB *gorm.DB
+}
+
+// Insert appends the movie at the end of the user watchlist
+func (m *WatchlistModel) Insert(item *WatchlistItem) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockWatchlist(tx, item.UserId)
+		if err != nil {
+			return err
+		}
+
+		query := tx.Raw("SELECT coalesce(max(position), 0) + 1 FROM watchlist_items WHERE user_id = ?", item.UserId).
+			Scan(&item.Position)
+		if query.Error != nil {
+			return query.Error
+		}
+
+		query = tx.Table("watchlist_items").Create(item)
+		if query.Error != nil {
+			switch {
+			case query.Error.Error() == `ERROR: duplicate key value violates unique constraint "watchlist_items_user_id_movie_id_key" (SQLSTATE 23505)`:
+				return ErrDuplicateWatchlistItem
+			default:
+				return query.Error
+			}
+		}
+		return nil
+	})
+}
+
+func (m *WatchlistModel) Get(userId, movieId int64) (*WatchlistItem, error) {
+	var item WatchlistItem
+	query := m.DB.Table("watchlist_items").Where("user_id = ? AND movie_id = ?", userId, movieId).Find(&item)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &item, nil
+}
+
+// Update saves the notes and priority of the item and moves it to position, shifting the items in between
+func (m *WatchlistModel) Update(item *WatchlistItem, position int32) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockWatchlist(tx, item.UserId)
+		if err != nil {
+			return err
+		}
+
+		// deleting a movie removes its entries without closing the gap, positions are made 1..count again first
+		err = renumberWatchlist(tx, item.UserId)
+		if err != nil {
+			return err
+		}
+
+		var current struct {
+			Position int32
+			Count    int32
+		}
+		query := tx.Raw(`
+			SELECT position, (SELECT count(*) FROM watchlist_items WHERE user_id = @user) AS count
+			FROM watchlist_items
+			WHERE user_id = @user AND movie_id = @movie`,
+			map[string]interface{}{"user": item.UserId, "movie": item.MovieId}).Scan(&current)
+		if query.Error != nil {
+			return query.Error
+		}
+		if query.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+
+		position = max(1, min(position, current.Count))
+		switch {
+		case position > current.Position:
+			err = tx.Exec(`
+				UPDATE watchlist_items SET position = position - 1
+				WHERE user_id = ? AND position > ? AND position <= ?`,
+				item.UserId, current.Position, position).Error
+		case position < current.Position:
+			err = tx.Exec(`
+				UPDATE watchlist_items SET position = position + 1
+				WHERE user_id = ? AND position >= ? AND position < ?`,
+				item.UserId, position, current.Position).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		item.Position = position
+		return tx.Exec(`
+			UPDATE watchlist_items SET position = ?, priority = ?, notes = ?
+			WHERE user_id = ? AND movie_id = ?`,
+			item.Position, item.Priority, item.Notes, item.UserId, item.MovieId).Error
+	})
+}
+
+func (m *WatchlistModel) Delete(userId, movieId int64) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockWatchlist(tx, userId)
+		if err != nil {
+			return err
+		}
+
+		removed, err := removeFromWatchlist(tx, userId, movieId)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// GetAll lists the user watchlist, the movies are filtered by the search criteria
+func (m *WatchlistModel) GetAll(userId int64, search MovieSearch, filters Filters) ([]*WatchlistItem, Metadata, error) {
+	var listItems []struct {
+		Count int
+		*WatchlistItem
+	}
+	q := m.DB.Table("watchlist_items").
+		Where("user_id = ?", userId).
+		Where("movie_id IN (?)", search.movieIds(m.DB)).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, *").
+		Find(&listItems)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	items := []*WatchlistItem{}
+	if len(listItems) == 0 {
+		return items, Metadata{}, nil
+	}
+	for _, item := range listItems {
+		items = append(items, item.WatchlistItem)
+	}
+	metadata := calculateMetadata(listItems[0].Count, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+type WatchedModel struct {
+	DB *gorm.DB
+}
+
+// Insert logs the movie as watched and takes it off the user watchlist in the same transaction
+func (m *WatchedModel) Insert(entry *WatchedEntry) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		err := lockWatchlist(tx, entry.UserId)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Table("watched_movies").Create(entry).Error
+		if err != nil {
+			return err
+		}
+
+		_, err = removeFromWatchlist(tx, entry.UserId, entry.MovieId)
+		return err
+	})
+}
+
+func (m *WatchedModel) Delete(userId, id int64) error {
+	query := m.DB.Table("watched_movies").Where("id = ? AND user_id = ?", id, userId).Delete(&WatchedEntry{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll lists the user watched history, the movies are filtered by the search criteria
+func (m *WatchedModel) GetAll(userId int64, search MovieSearch, filters Filters) ([]*WatchedEntry, Metadata, error) {
+	var listEntries []struct {
+		Count int
+		*WatchedEntry
+	}
+	q := m.DB.Table("watched_movies").
+		Where("user_id = ?", userId).
+		Where("movie_id IN (?)", search.movieIds(m.DB)).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, *").
+		Find(&listEntries)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	entries := []*WatchedEntry{}
+	if len(listEntries) == 0 {
+		return entries, Metadata{}, nil
+	}
+	for _, entry := range listEntries {
+		entries = append(entries, entry.WatchedEntry)
+	}
+	metadata := calculateMetadata(listEntries[0].Count, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
+
+// lockWatchlist serializes the changes to the positions of a user watchlist
+func lockWatchlist(tx *gorm.DB, userId int64) error {
+	return tx.Exec("SELECT id FROM users WHERE id = ? FOR UPDATE", userId).Error
+}
+
+// removeFromWatchlist deletes the item and closes the gap it leaves in the positions
+func removeFromWatchlist(tx *gorm.DB, userId, movieId int64) (bool, error) {
+	var position int32
+	query := tx.Raw("DELETE FROM watchlist_items WHERE user_id = ? AND movie_id = ? RETURNING position", userId, movieId).
+		Scan(&position)
+	if query.Error != nil {
+		return false, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return false, nil
+	}
+
+	err := tx.Exec("UPDATE watchlist_items SET position = position - 1 WHERE user_id = ? AND position > ?", userId, position).Error
+	return err == nil, err
+}
+
+// renumberWatchlist sets the positions of the user watchlist back to 1..count keeping their order
+func renumberWatchlist(tx *gorm.DB, userId int64) error {
+	return tx.Exec(`
+		UPDATE watchlist_items SET position = ranked.position
+		FROM (SELECT id, row_number() OVER (ORDER BY position, id) AS position
+			FROM watchlist_items WHERE user_id = ?) AS ranked
+		WHERE watchlist_items.id = ranked.id AND watchlist_items.position <> ranked.position`, userId).Error
+}
+
+type WatchlistItem struct {
+	Id       int64     `json:"id"`
+	AddedAt  time.Time `json:"added_at" gorm:"autoCreateTime"`
+	UserId   int64     `json:"-"`
+	MovieId  int64     `json:"movie_id"`
+	Position int32     `json:"position"`
+	Priority int32     `json:"priority"`
+	Notes    string    `json:"notes,omitempty"`
+	Movie    *Movie    `json:"movie,omitempty" gorm:"-"`
+}
+
+type WatchedEntry struct {
+	Id        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	UserId    int64     `json:"-"`
+	MovieId   int64     `json:"movie_id"`
+	WatchedAt time.Time `json:"watched_at"`
+	Movie     *Movie    `json:"movie,omitempty" gorm:"-"`
+}
+
+func ValidateWatchlistItem(v *validator.Validator, item *WatchlistItem) {
+	v.Check(item.MovieId > 0, "movie_id", "must be provided")
+	v.Check(item.Priority >= 0, "priority", "must not be negative")
+	v.Check(item.Priority <= 5, "priority", "must not be more than 5")
+	v.Check(len(item.Notes) <= 1000, "notes", "must not be more than 1000 bytes long")
+}
+
+func ValidateWatchedEntry(v *validator.Validator, entry *WatchedEntry) {
+	v.Check(entry.MovieId > 0, "movie_id", "must be provided")
+	v.Check(!entry.WatchedAt.IsZero(), "watched_at", "must be provided")
+	v.Check(!entry.WatchedAt.After(time.Now()), "watched_at", "must not be in the future")
+}
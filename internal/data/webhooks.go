@@ -0,0 +1,331 @@
+package data
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"github.com/duongbm/greenlight-gin/internal/webhook"
+	pq "github.com/lib/pq"
+	"gorm.io/gorm"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+var (
+	// WebhookEvents are the catalogue events webhooks can subscribe to, one per kind of movie change
+	WebhookEvents = []string{"movie." + ChangeCreated, "movie." + ChangeUpdated, "movie." + ChangeDeleted}
+
+	DeliveryStatuses = []string{DeliveryPending, DeliverySucceeded, DeliveryDead}
+)
+
+type WebhookModel struct {
+	DB *gorm.DB
+}
+
+func (m *WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+		INSERT INTO webhooks (user_id, url, events, secret, active)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, created_at, version`
+
+	return m.DB.Raw(query, webhook.UserId, webhook.URL, webhook.Events, webhook.Secret, webhook.Active).Scan(webhook).Error
+}
+
+// GetForUser fetches a webhook of the user, the webhooks of other users are reported as ErrRecordNotFound
+func (m *WebhookModel) GetForUser(userId, id int64) (*Webhook, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var webhook Webhook
+	query := m.DB.Table("webhooks").Where("id = ? AND user_id = ?", id, userId).Find(&webhook)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &webhook, nil
+}
+
+func (m *WebhookModel) GetAllForUser(userId int64) ([]*Webhook, error) {
+	webhooks := []*Webhook{}
+	query := m.DB.Table("webhooks").Where("user_id = ?", userId).Order("id").Find(&webhooks)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return webhooks, nil
+}
+
+func (m *WebhookModel) Update(webhook *Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = ?, events = ?, active = ?, version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version`
+
+	tx := m.DB.Raw(query, webhook.URL, webhook.Events, webhook.Active, webhook.Id, webhook.Version).Scan(&webhook.Version)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// Delete removes the webhook along with its delivery log
+func (m *WebhookModel) Delete(webhook *Webhook) error {
+	query := m.DB.Table("webhooks").Where("id = ? AND version = ?", webhook.Id, webhook.Version).Delete(&Webhook{})
+	if query.Error != nil {
+		return query.Error
+	}
+	if query.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// GetDeliveries returns the delivery log of the webhook, the latest deliveries first
+func (m *WebhookModel) GetDeliveries(webhookId int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	var listDeliveries []struct {
+		Count int
+		*WebhookDelivery
+	}
+	q := m.DB.Table("webhook_deliveries").
+		Where("webhook_id = ?", webhookId).
+		Where("(status = @status OR @status = '')", map[string]interface{}{"status": status}).
+		Order(filters.orderBy()).
+		Limit(filters.limit()).
+		Offset(filters.offset()).
+		Select("count(*) OVER() as count, webhook_deliveries.*").
+		Find(&listDeliveries)
+	if q.Error != nil {
+		return nil, Metadata{}, q.Error
+	}
+
+	deliveries := []*WebhookDelivery{}
+	if len(listDeliveries) == 0 {
+		return deliveries, Metadata{}, nil
+	}
+	for _, item := range listDeliveries {
+		deliveries = append(deliveries, item.WebhookDelivery)
+	}
+	metadata := calculateMetadata(listDeliveries[0].Count, filters.Page, filters.PageSize)
+	return deliveries, metadata, nil
+}
+
+// GetDelivery fetches a delivery of the webhook along with its attempts
+func (m *WebhookModel) GetDelivery(webhookId, id int64) (*WebhookDelivery, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var delivery WebhookDelivery
+	query := m.DB.Table("webhook_deliveries").Where("id = ? AND webhook_id = ?", id, webhookId).Find(&delivery)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	if query.RowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	delivery.Attempts = []*WebhookAttempt{}
+	err := m.DB.Table("webhook_attempts").Where("delivery_id = ?", delivery.Id).Order("id").Find(&delivery.Attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Redeliver queues the delivery again right away with a fresh backoff, whatever its status
+func (m *WebhookModel) Redeliver(delivery *WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempt_count = 0, next_attempt_at = NOW()
+		WHERE id = ?
+		RETURNING status, attempt_count, next_attempt_at`
+
+	return m.DB.Raw(query, delivery.Id).Scan(delivery).Error
+}
+
+// ClaimDue leases up to limit deliveries which are due. Their next attempt moves lease ahead so no other
+// API instance picks them while they are being sent, a delivery whose sender dies is retried once the lease
+// expires, so deliveries happen at least once
+func (m *WebhookModel) ClaimDue(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries
+		SET next_attempt_at = NOW() + make_interval(secs => ?)
+		FROM due, webhooks
+		WHERE webhook_deliveries.id = due.id AND webhooks.id = webhook_deliveries.webhook_id
+		RETURNING webhook_deliveries.*, webhooks.url, webhooks.secret`
+
+	deliveries := []*WebhookDelivery{}
+	err := m.DB.Raw(query, limit, lease.Seconds()).Scan(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt logs the outcome of sending the delivery. A failed delivery is retried after the backoff
+// doubled for every attempt so far, up to maxBackoff, and is dead once it made maxAttempts attempts
+func (m *WebhookModel) RecordAttempt(delivery *WebhookDelivery, attempt *WebhookAttempt, maxAttempts int, backoff, maxBackoff time.Duration) error {
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		attempt.DeliveryId = delivery.Id
+		err := tx.Table("webhook_attempts").Create(attempt).Error
+		if err != nil {
+			return err
+		}
+
+		delivery.recordAttempt(attempt, maxAttempts, backoff, maxBackoff, time.Now())
+
+		query := `
+			UPDATE webhook_deliveries
+			SET status = ?, attempt_count = ?, next_attempt_at = ?, last_status_code = ?, last_latency_ms = ?,
+				last_error = ?, delivered_at = ?
+			WHERE id = ?`
+
+		return tx.Exec(query, delivery.Status, delivery.AttemptCount, delivery.NextAttemptAt, delivery.LastStatusCode,
+			delivery.LastLatencyMs, delivery.LastError, delivery.DeliveredAt, delivery.Id).Error
+	})
+}
+
+// recordAttempt moves the delivery to the state following the attempt made at now, see RecordAttempt
+func (delivery *WebhookDelivery) recordAttempt(attempt *WebhookAttempt, maxAttempts int, backoff, maxBackoff time.Duration, now time.Time) {
+	delivery.AttemptCount++
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastLatencyMs = &attempt.LatencyMs
+	delivery.LastError = attempt.Error
+	switch {
+	case attempt.Error == "":
+		delivery.Status = DeliverySucceeded
+		delivery.DeliveredAt = &now
+	case delivery.AttemptCount >= maxAttempts:
+		delivery.Status = DeliveryDead
+	default:
+		delay := backoff
+		for i := 1; i < delivery.AttemptCount && delay < maxBackoff; i++ {
+			delay *= 2
+		}
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+		delivery.NextAttemptAt = now.Add(delay)
+	}
+}
+
+// enqueueWebhookDeliveries queues a delivery of each change to the active webhooks subscribed to it, within
+// the transaction which recorded the changes. Webhooks see changes as the public does
+func enqueueWebhookDeliveries(tx *gorm.DB, seqs []int64) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT webhooks.id, changes.event, json_build_object(
+			'event', changes.event,
+			'movie_id', changes.movie_id,
+			'version', changes.version,
+			'changed_at', changes.changed_at)
+		FROM (
+			SELECT movie_changes.*, 'movie.' || CASE WHEN kind = 'deleted' OR NOT EXISTS (
+				SELECT 1 FROM movies WHERE movies.id = movie_changes.movie_id AND ` + publicMovieCondition + `
+			) THEN 'deleted' ELSE kind END AS event
+			FROM movie_changes
+			WHERE seq = ANY(?)
+		) AS changes
+		INNER JOIN webhooks ON webhooks.active AND changes.event = ANY(webhooks.events)
+		ORDER BY changes.seq, webhooks.id`
+
+	return tx.Exec(query, pq.Array(seqs)).Error
+}
+
+// GenerateWebhookSecret returns a random secret deliveries are signed with
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+type Webhook struct {
+	Id        int64          `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UserId    int64          `json:"-"`
+	URL       string         `json:"url" gorm:"column:url"`
+	Events    pq.StringArray `json:"events" gorm:"type:text[]"`
+	Secret    string         `json:"-"`
+	Active    bool           `json:"active"`
+	Version   int32          `json:"version"`
+}
+
+type WebhookDelivery struct {
+	Id             int64             `json:"id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	WebhookId      int64             `json:"webhook_id"`
+	Event          string            `json:"event"`
+	Payload        json.RawMessage   `json:"payload"`
+	Status         string            `json:"status"`
+	AttemptCount   int               `json:"attempt_count"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastStatusCode *int              `json:"last_status_code"`
+	LastLatencyMs  *int              `json:"last_latency_ms"`
+	LastError      string            `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+	Attempts       []*WebhookAttempt `json:"attempts,omitempty" gorm:"-"`
+	// URL and Secret of the webhook, only loaded for sending
+	URL    string `json:"-" gorm:"column:url;->"`
+	Secret string `json:"-" gorm:"->"`
+}
+
+type WebhookAttempt struct {
+	Id          int64     `json:"-"`
+	DeliveryId  int64     `json:"-"`
+	AttemptedAt time.Time `json:"attempted_at" gorm:"autoCreateTime"`
+	StatusCode  *int      `json:"status_code"`
+	LatencyMs   int       `json:"latency_ms"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// isPrivateHost reports whether host is localhost or a literal private address
+func isPrivateHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && webhook.IsPrivateIP(ip)
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(len(webhook.URL) <= 2000, "url", "must not be more than 2000 bytes long")
+	v.Check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "", "url", "must be an absolute http or https URL")
+	if err == nil {
+		// deliveries to private addresses are refused when dialing as well, this only catches the obvious ones early
+		v.Check(!isPrivateHost(u.Hostname()), "url", "must not point to a private address")
+	}
+
+	v.Check(len(webhook.Events) >= 1, "events", "must contain at least 1 event")
+	v.Check(validator.Unique(webhook.Events), "events", "must not contain duplicate values")
+	for _, event := range webhook.Events {
+		v.Check(validator.In(event, WebhookEvents...), "events", "must only contain movie.created, movie.updated or movie.deleted")
+	}
+}
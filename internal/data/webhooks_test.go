@@ -0,0 +1,88 @@
+package data
+
+import (
+	"github.com/duongbm/greenlight-gin/internal/validator"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliveryRecordAttempt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	backoff := 30 * time.Second
+	maxBackoff := 5 * time.Minute
+	maxAttempts := 8
+
+	tests := []struct {
+		name          string
+		attemptCount  int
+		err           string
+		wantStatus    string
+		wantNextDelay time.Duration
+	}{
+		{"first failure waits the backoff", 0, "unexpected status code 500", DeliveryPending, 30 * time.Second},
+		{"second failure doubles the backoff", 1, "unexpected status code 500", DeliveryPending, time.Minute},
+		{"third failure doubles again", 2, "unexpected status code 500", DeliveryPending, 2 * time.Minute},
+		{"backoff is capped", 5, "connection refused", DeliveryPending, maxBackoff},
+		{"backoff stays capped", 6, "connection refused", DeliveryPending, maxBackoff},
+		{"last attempt is dead", 7, "connection refused", DeliveryDead, 0},
+		{"success", 3, "", DeliverySucceeded, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &WebhookDelivery{Status: DeliveryPending, AttemptCount: tt.attemptCount}
+			statusCode := 500
+			attempt := &WebhookAttempt{StatusCode: &statusCode, LatencyMs: 42, Error: tt.err}
+
+			delivery.recordAttempt(attempt, maxAttempts, backoff, maxBackoff, now)
+
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", delivery.Status, tt.wantStatus)
+			}
+			if delivery.AttemptCount != tt.attemptCount+1 {
+				t.Errorf("attempt count = %d, want %d", delivery.AttemptCount, tt.attemptCount+1)
+			}
+			if *delivery.LastLatencyMs != 42 || delivery.LastError != tt.err {
+				t.Errorf("last attempt = (%d, %q), want (42, %q)", *delivery.LastLatencyMs, delivery.LastError, tt.err)
+			}
+			if tt.wantStatus == DeliveryPending && !delivery.NextAttemptAt.Equal(now.Add(tt.wantNextDelay)) {
+				t.Errorf("next attempt in %s, want %s", delivery.NextAttemptAt.Sub(now), tt.wantNextDelay)
+			}
+			if tt.wantStatus == DeliverySucceeded && (delivery.DeliveredAt == nil || !delivery.DeliveredAt.Equal(now)) {
+				t.Errorf("delivered at = %v, want %v", delivery.DeliveredAt, now)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/hooks", true},
+		{"http://93.184.216.34/hooks", true},
+		{"ftp://example.com/hooks", false},
+		{"/hooks", false},
+		{"http://localhost:8080/hooks", false},
+		{"http://api.localhost/hooks", false},
+		{"http://127.0.0.1/hooks", false},
+		{"http://10.0.0.8/hooks", false},
+		{"http://192.168.1.1/hooks", false},
+		{"http://172.16.0.1/hooks", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://0.0.0.0/hooks", false},
+		{"http://[::1]/hooks", false},
+		{"http://[fd00::1]/hooks", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			v := validator.New()
+			ValidateWebhook(v, &Webhook{URL: tt.url, Events: []string{"movie.created"}})
+			if v.Valid() != tt.valid {
+				t.Errorf("valid = %t, want %t (errors: %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
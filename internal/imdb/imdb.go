@@ -0,0 +1,137 @@
+package imdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// null is how the dumps spell a missing value
+const null = `\N`
+
+var (
+	ErrInvalidHeader = errors.New("imdb: not a title.basics dataset")
+)
+
+var basicsColumns = []string{"tconst", "titleType", "primaryTitle", "originalTitle", "isAdult", "startYear", "endYear", "runtimeMinutes", "genres"}
+
+// Title is a row of title.basics, missing numbers are zero and missing genres are nil
+type Title struct {
+	Tconst         string
+	TitleType      string
+	PrimaryTitle   string
+	OriginalTitle  string
+	IsAdult        bool
+	StartYear      int32
+	RuntimeMinutes int32
+	Genres         []string
+}
+
+// Reader streams the titles of a title.basics dump, gzipped or not
+type Reader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewReader checks the header of the dump, gzipped dumps are decompressed on the fly
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReaderSize(gz, 64*1024)
+	}
+
+	reader := &Reader{r: br}
+	header, err := reader.readLine()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrInvalidHeader
+		}
+		return nil, err
+	}
+	if strings.Join(header, "\t") != strings.Join(basicsColumns, "\t") {
+		return nil, ErrInvalidHeader
+	}
+	return reader, nil
+}
+
+// Line returns the number of the line of the dump the last title was read from
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Next returns the next title of the dump, or io.EOF once all titles were read. A malformed row
+// is reported as a *RowError and reading can carry on with the next one
+func (r *Reader) Next() (*Title, error) {
+	fields, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != len(basicsColumns) {
+		return nil, &RowError{Line: r.line, Err: fmt.Errorf("expected %d fields, got %d", len(basicsColumns), len(fields))}
+	}
+
+	title := &Title{
+		Tconst:        fields[0],
+		TitleType:     fields[1],
+		PrimaryTitle:  fields[2],
+		OriginalTitle: fields[3],
+		IsAdult:       fields[4] == "1",
+	}
+	title.StartYear, err = parseNumber(fields[5])
+	if err != nil {
+		return nil, &RowError{Line: r.line, Tconst: title.Tconst, Err: fmt.Errorf("startYear: %w", err)}
+	}
+	title.RuntimeMinutes, err = parseNumber(fields[7])
+	if err != nil {
+		return nil, &RowError{Line: r.line, Tconst: title.Tconst, Err: fmt.Errorf("runtimeMinutes: %w", err)}
+	}
+	if fields[8] != null && fields[8] != "" {
+		title.Genres = strings.Split(fields[8], ",")
+	}
+	return title, nil
+}
+
+func (r *Reader) readLine() ([]string, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return nil, err
+	}
+	r.line++
+	line = strings.TrimRight(line, "\r\n")
+	return strings.Split(line, "\t"), nil
+}
+
+func parseNumber(s string) (int32, error) {
+	if s == null {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return int32(n), nil
+}
+
+// RowError reports a row of the dump which couldn't be parsed
+type RowError struct {
+	Line   int
+	Tconst string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("imdb: line %d: %s", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
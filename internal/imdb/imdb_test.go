@@ -0,0 +1,167 @@
+package imdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const header = "tconst\ttitleType\tprimaryTitle\toriginalTitle\tisAdult\tstartYear\tendYear\truntimeMinutes\tgenres\n"
+
+// readAll reads every title of the dump, row errors are collected and reading carries on
+func readAll(t *testing.T, r *Reader) ([]*Title, []*RowError) {
+	t.Helper()
+
+	var titles []*Title
+	var rowErrors []*RowError
+	for {
+		title, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return titles, rowErrors
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rowErrors = append(rowErrors, rowErr)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, title)
+	}
+}
+
+func TestReaderNext(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		want *Title
+	}{
+		{
+			name: "complete row",
+			row:  "tt0111161\tmovie\tThe Shawshank Redemption\tThe Shawshank Redemption\t0\t1994\t\\N\t142\tDrama",
+			want: &Title{Tconst: "tt0111161", TitleType: "movie", PrimaryTitle: "The Shawshank Redemption", OriginalTitle: "The Shawshank Redemption", StartYear: 1994, RuntimeMinutes: 142, Genres: []string{"Drama"}},
+		},
+		{
+			name: "several genres",
+			row:  "tt0133093\tmovie\tThe Matrix\tThe Matrix\t0\t1999\t\\N\t136\tAction,Sci-Fi",
+			want: &Title{Tconst: "tt0133093", TitleType: "movie", PrimaryTitle: "The Matrix", OriginalTitle: "The Matrix", StartYear: 1999, RuntimeMinutes: 136, Genres: []string{"Action", "Sci-Fi"}},
+		},
+		{
+			name: "missing values",
+			row:  "tt0000001\tshort\tCarmencita\tCarmencita\t0\t\\N\t\\N\t\\N\t\\N",
+			want: &Title{Tconst: "tt0000001", TitleType: "short", PrimaryTitle: "Carmencita", OriginalTitle: "Carmencita"},
+		},
+		{
+			name: "empty genres",
+			row:  "tt0000002\tmovie\tUntitled\tUntitled\t0\t1900\t\\N\t10\t",
+			want: &Title{Tconst: "tt0000002", TitleType: "movie", PrimaryTitle: "Untitled", OriginalTitle: "Untitled", StartYear: 1900, RuntimeMinutes: 10},
+		},
+		{
+			name: "adult title",
+			row:  "tt0000003\tmovie\tAdult\tAdult\t1\t2001\t\\N\t90\tAdult",
+			want: &Title{Tconst: "tt0000003", TitleType: "movie", PrimaryTitle: "Adult", OriginalTitle: "Adult", IsAdult: true, StartYear: 2001, RuntimeMinutes: 90, Genres: []string{"Adult"}},
+		},
+		{
+			name: "windows line ending",
+			row:  "tt0000004\tmovie\tCRLF\tCRLF\t0\t2002\t\\N\t95\tComedy\r",
+			want: &Title{Tconst: "tt0000004", TitleType: "movie", PrimaryTitle: "CRLF", OriginalTitle: "CRLF", StartYear: 2002, RuntimeMinutes: 95, Genres: []string{"Comedy"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(header + tt.row + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			titles, rowErrors := readAll(t, r)
+			if len(rowErrors) > 0 {
+				t.Fatalf("unexpected row error: %v", rowErrors[0])
+			}
+			if len(titles) != 1 || !reflect.DeepEqual(titles[0], tt.want) {
+				t.Errorf("titles = %+v, want %+v", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderRowErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		row        string
+		wantTconst string
+		wantErr    string
+	}{
+		{"missing fields", "tt0000005\tmovie\tShort row", "", "expected 9 fields, got 3"},
+		{"extra fields", "tt0000006\tmovie\tA\tA\t0\t2000\t\\N\t90\tDrama\textra", "", "expected 9 fields, got 10"},
+		{"invalid year", "tt0000007\tmovie\tA\tA\t0\t19x4\t\\N\t90\tDrama", "tt0000007", `startYear: invalid number "19x4"`},
+		{"invalid runtime", "tt0000008\tmovie\tA\tA\t0\t1994\t\\N\tlong\tDrama", "tt0000008", `runtimeMinutes: invalid number "long"`},
+		{"runtime out of range", "tt0000009\tmovie\tA\tA\t0\t1994\t\\N\t99999999999\tDrama", "tt0000009", `runtimeMinutes: invalid number "99999999999"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the malformed row is surrounded by valid ones which are still read
+			valid := "tt0000010\tmovie\tValid\tValid\t0\t2010\t\\N\t100\tDrama\n"
+			r, err := NewReader(strings.NewReader(header + valid + tt.row + "\n" + valid))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			titles, rowErrors := readAll(t, r)
+			if len(titles) != 2 {
+				t.Errorf("read %d titles, want the 2 valid ones", len(titles))
+			}
+			if len(rowErrors) != 1 {
+				t.Fatalf("got %d row errors, want 1", len(rowErrors))
+			}
+
+			rowErr := rowErrors[0]
+			if rowErr.Line != 3 {
+				t.Errorf("line = %d, want 3", rowErr.Line)
+			}
+			if rowErr.Tconst != tt.wantTconst {
+				t.Errorf("tconst = %q, want %q", rowErr.Tconst, tt.wantTconst)
+			}
+			if rowErr.Err.Error() != tt.wantErr {
+				t.Errorf("err = %q, want %q", rowErr.Err, tt.wantErr)
+			}
+			if !strings.HasPrefix(rowErr.Error(), "imdb: line 3: ") {
+				t.Errorf("Error() = %q, want the line number", rowErr.Error())
+			}
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(header + "tt0000011\tmovie\tZipped\tZipped\t0\t2011\t\\N\t80\tDrama"))
+	gz.Close()
+
+	r, err := NewReader(&gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles, _ := readAll(t, r)
+	if len(titles) != 1 || titles[0].PrimaryTitle != "Zipped" {
+		t.Errorf("titles = %+v, want the zipped title without a trailing newline", titles)
+	}
+	if r.Line() != 2 {
+		t.Errorf("line = %d, want 2", r.Line())
+	}
+
+	for name, dump := range map[string]string{
+		"empty":         "",
+		"other dataset": "nconst\tprimaryName\tbirthYear\tdeathYear\tprimaryProfession\tknownForTitles\n",
+	} {
+		if _, err := NewReader(strings.NewReader(dump)); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: err = %v, want ErrInvalidHeader", name, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrTestFailed   = errors.New("test operation failed")
+)
+
+// Operation is a single RFC 6902 JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch applies a RFC 7396 JSON Merge Patch to doc
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// Apply applies a RFC 6902 JSON Patch to doc, only the add, remove, replace and test operations are supported
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var operations []Operation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+
+	for i, operation := range operations {
+		var err error
+		target, err = apply(target, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func apply(doc interface{}, operation Operation) (interface{}, error) {
+	tokens, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if operation.Op != "remove" {
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("%w: %q requires a value", ErrInvalidPatch, operation.Op)
+		}
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+		}
+	}
+
+	switch operation.Op {
+	case "add":
+		return update(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+			return add(parent, key, value)
+		})
+	case "remove":
+		return update(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+			return remove(parent, key)
+		})
+	case "replace":
+		return update(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+			parent, err := remove(parent, key)
+			if err != nil {
+				return nil, err
+			}
+			return add(parent, key, value)
+		})
+	case "test":
+		current, err := get(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w: %s", ErrTestFailed, operation.Path)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported operation %q", ErrInvalidPatch, operation.Op)
+	}
+}
+
+// parsePointer splits a RFC 6901 JSON Pointer into its reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[i])
+	}
+	return tokens, nil
+}
+
+// update walks doc down to the parent of the last token and replaces it with the result of fn
+func update(doc interface{}, tokens []string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: the whole document can not be patched", ErrInvalidPatch)
+	}
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+
+	child, err := get(doc, tokens[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = update(child, tokens[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := doc.(type) {
+	case map[string]interface{}:
+		parent[tokens[0]] = child
+	case []interface{}:
+		i, _ := strconv.Atoi(tokens[0])
+		parent[i] = child
+	}
+	return doc, nil
+}
+
+func get(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: path member %q does not exist", ErrInvalidPatch, token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: path member %q does not exist", ErrInvalidPatch, token)
+		}
+	}
+	return doc, nil
+}
+
+func add(parent interface{}, key string, value interface{}) (interface{}, error) {
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[key] = value
+		return node, nil
+	case []interface{}:
+		if key == "-" {
+			return append(node, value), nil
+		}
+		i, err := arrayIndex(key, len(node))
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: path member %q does not exist", ErrInvalidPatch, key)
+	}
+}
+
+func remove(parent interface{}, key string) (interface{}, error) {
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := node[key]; !ok {
+			return nil, fmt.Errorf("%w: path member %q does not exist", ErrInvalidPatch, key)
+		}
+		delete(node, key)
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(key, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		return append(node[:i], node[i+1:]...), nil
+	default:
+		return nil, fmt.Errorf("%w: path member %q does not exist", ErrInvalidPatch, key)
+	}
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, token)
+	}
+	return i, nil
+}
@@ -0,0 +1,284 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid result %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("invalid expectation %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	doc := `{"title":"Moana","genres":["animation","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`
+
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "add a member",
+			patch: `[{"op":"add","path":"/year","value":2016}]`,
+			want:  `{"title":"Moana","year":2016,"genres":["animation","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "add replaces an existing member",
+			patch: `[{"op":"add","path":"/title","value":"Vaiana"}]`,
+			want:  `{"title":"Vaiana","genres":["animation","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "add inserts into an array",
+			patch: `[{"op":"add","path":"/genres/1","value":"musical"}]`,
+			want:  `{"title":"Moana","genres":["animation","musical","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "add at the array length appends",
+			patch: `[{"op":"add","path":"/genres/2","value":"musical"}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure","musical"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "add with - appends",
+			patch: `[{"op":"add","path":"/genres/-","value":"musical"}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure","musical"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "add into a nested object",
+			patch: `[{"op":"add","path":"/cast/villain","value":"Tamatoa"}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i","villain":"Tamatoa"}}`,
+		},
+		{
+			name:    "add past the array length",
+			patch:   `[{"op":"add","path":"/genres/3","value":"musical"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "add below a missing member",
+			patch:   `[{"op":"add","path":"/crew/director","value":"Ron Clements"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "remove a member",
+			patch: `[{"op":"remove","path":"/cast"}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure"],"a/b":1,"m~n":2}`,
+		},
+		{
+			name:  "remove an array element",
+			patch: `[{"op":"remove","path":"/genres/0"}]`,
+			want:  `{"title":"Moana","genres":["adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:    "remove a missing member",
+			patch:   `[{"op":"remove","path":"/year"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "remove with - is not an index",
+			patch:   `[{"op":"remove","path":"/genres/-"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "remove with a leading zero index",
+			patch:   `[{"op":"remove","path":"/genres/01"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "replace a member",
+			patch: `[{"op":"replace","path":"/title","value":"Vaiana"}]`,
+			want:  `{"title":"Vaiana","genres":["animation","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "replace the last array element",
+			patch: `[{"op":"replace","path":"/genres/1","value":"musical"}]`,
+			want:  `{"title":"Moana","genres":["animation","musical"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:    "replace a missing member",
+			patch:   `[{"op":"replace","path":"/year","value":2016}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "replace without a value",
+			patch:   `[{"op":"replace","path":"/title"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "test then replace",
+			patch: `[{"op":"test","path":"/genres","value":["animation","adventure"]},{"op":"replace","path":"/title","value":"Vaiana"}]`,
+			want:  `{"title":"Vaiana","genres":["animation","adventure"],"a/b":1,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:    "failed test stops the patch",
+			patch:   `[{"op":"test","path":"/title","value":"Vaiana"},{"op":"remove","path":"/cast"}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:  "test a nested member",
+			patch: `[{"op":"test","path":"/cast/lead","value":"Auli'i"}]`,
+			want:  doc,
+		},
+		{
+			name:    "test a missing member",
+			patch:   `[{"op":"test","path":"/year","value":2016}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:  "~1 escapes a slash",
+			patch: `[{"op":"replace","path":"/a~1b","value":10}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure"],"a/b":10,"m~n":2,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "~0 escapes a tilde",
+			patch: `[{"op":"test","path":"/m~0n","value":2},{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure"],"a/b":1,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "~01 is a tilde followed by 1",
+			patch: `[{"op":"add","path":"/~01","value":true}]`,
+			want:  `{"title":"Moana","genres":["animation","adventure"],"a/b":1,"m~n":2,"~1":true,"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:    "path without a leading slash",
+			patch:   `[{"op":"remove","path":"title"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "whole document",
+			patch:   `[{"op":"replace","path":"","value":{}}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "unsupported operation",
+			patch:   `[{"op":"move","from":"/title","path":"/name"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "not a patch",
+			patch:   `{"op":"remove","path":"/title"}`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "replace a member",
+			doc:   `{"title":"Moana","year":2016}`,
+			patch: `{"title":"Vaiana"}`,
+			want:  `{"title":"Vaiana","year":2016}`,
+		},
+		{
+			name:  "add a member",
+			doc:   `{"title":"Moana"}`,
+			patch: `{"year":2016}`,
+			want:  `{"title":"Moana","year":2016}`,
+		},
+		{
+			name:  "null removes a member",
+			doc:   `{"title":"Moana","year":2016}`,
+			patch: `{"year":null}`,
+			want:  `{"title":"Moana"}`,
+		},
+		{
+			name:  "null removes a nested member",
+			doc:   `{"cast":{"lead":"Auli'i","villain":"Tamatoa"}}`,
+			patch: `{"cast":{"villain":null}}`,
+			want:  `{"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "null for a missing member",
+			doc:   `{"title":"Moana"}`,
+			patch: `{"year":null}`,
+			want:  `{"title":"Moana"}`,
+		},
+		{
+			name:  "null is dropped from added objects",
+			doc:   `{"title":"Moana"}`,
+			patch: `{"cast":{"lead":"Auli'i","villain":null}}`,
+			want:  `{"title":"Moana","cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "arrays are replaced",
+			doc:   `{"genres":["animation","adventure"]}`,
+			patch: `{"genres":["musical"]}`,
+			want:  `{"genres":["musical"]}`,
+		},
+		{
+			name:  "an object replaces a scalar",
+			doc:   `{"cast":"unknown"}`,
+			patch: `{"cast":{"lead":"Auli'i"}}`,
+			want:  `{"cast":{"lead":"Auli'i"}}`,
+		},
+		{
+			name:  "a non object patch replaces the document",
+			doc:   `{"title":"Moana"}`,
+			patch: `["Moana"]`,
+			want:  `["Moana"]`,
+		},
+		{
+			name:  "empty patch",
+			doc:   `{"title":"Moana"}`,
+			patch: `{}`,
+			want:  `{"title":"Moana"}`,
+		},
+		{
+			name:    "invalid patch",
+			doc:     `{"title":"Moana"}`,
+			patch:   `{"title":`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
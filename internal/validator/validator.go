@@ -4,6 +4,11 @@ import "regexp"
 
 var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+	// external identifiers of movies in outside datasets, e.g. tt0111161, 278 and Q172241
+	ImdbIdRX     = regexp.MustCompile(`^tt\d{7,10}$`)
+	TmdbIdRX     = regexp.MustCompile(`^[1-9]\d{0,9}$`)
+	WikidataIdRX = regexp.MustCompile(`^Q[1-9]\d{0,11}$`)
 )
 
 type Validator struct {
@@ -45,8 +50,8 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
-func Unique(values []string) bool {
-	uniqueValues := make(map[string]bool)
+func Unique[T comparable](values []T) bool {
+	uniqueValues := make(map[T]bool)
 
 	for _, value := range values {
 		uniqueValues[value] = true
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	EventHeader     = "X-Greenlight-Event"
+	DeliveryHeader  = "X-Greenlight-Delivery"
+	TimestampHeader = "X-Greenlight-Timestamp"
+	SignatureHeader = "X-Greenlight-Signature"
+)
+
+// ErrPrivateAddress is returned when a webhook URL resolves to an address of the internal network
+var ErrPrivateAddress = errors.New("webhook: refusing to connect to a private address")
+
+// IsPrivateIP reports whether ip is a loopback, private, link-local, multicast or unspecified address,
+// webhooks are never delivered to these so they can't be used to reach the internal network
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// denyPrivateAddress is a net.Dialer control function rejecting private addresses. It runs once the host is
+// resolved, right before connecting, so a host resolving to another address later (DNS rebinding) is
+// caught as well
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsPrivateIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+type Sender struct {
+	client *http.Client
+}
+
+// New returns a Sender which refuses to deliver to private addresses
+func New(timeout time.Duration) Sender {
+	return newSender(timeout, denyPrivateAddress)
+}
+
+func newSender(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) Sender {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// going through a proxy would dial the proxy rather than the receiver, bypassing the address check
+	transport.Proxy = nil
+
+	return Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// a redirect would resend the payload to a URL the subscriber didn't register
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Result describes a delivery attempt, StatusCode is zero when no response came back
+type Result struct {
+	StatusCode int
+	Latency    time.Duration
+}
+
+// OK reports whether the receiver acknowledged the delivery
+func (r Result) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sign returns the hex HMAC-SHA256 of the timestamp and the body joined by a dot, signing the timestamp lets
+// receivers reject replayed deliveries
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts the signed body to url
+func (s Sender) Send(url, secret, event string, deliveryId int64, body []byte) (Result, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Greenlight-Webhook/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(deliveryId, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Latency: time.Since(start)}, err
+	}
+	defer resp.Body.Close()
+
+	// drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return Result{StatusCode: resp.StatusCode, Latency: time.Since(start)}, nil
+}
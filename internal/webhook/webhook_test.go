@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"movie.created","movie_id":1}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("whsec_test", 1700000001, body) == want {
+		t.Error("signature must depend on the timestamp")
+	}
+	if Sign("whsec_other", 1700000000, body) == want {
+		t.Error("signature must depend on the secret")
+	}
+}
+
+func TestSenderSend(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"event":"movie.updated","movie_id":7,"version":3}`)
+
+	var received *http.Request
+	var receivedBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	// the test server listens on loopback, which New refuses
+	sender := newSender(5*time.Second, nil)
+	result, err := sender.Send(srv.URL, secret, "movie.updated", 42, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.OK() || result.StatusCode != http.StatusAccepted {
+		t.Errorf("result = %+v, want a 202", result)
+	}
+	if result.Latency <= 0 {
+		t.Errorf("latency = %s, want it measured", result.Latency)
+	}
+
+	if received.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", received.Method)
+	}
+	if string(receivedBody) != string(body) {
+		t.Errorf("body = %s, want %s", receivedBody, body)
+	}
+	for header, want := range map[string]string{
+		"Content-Type": "application/json",
+		EventHeader:    "movie.updated",
+		DeliveryHeader: "42",
+	} {
+		if got := received.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	timestamp, err := strconv.ParseInt(received.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age < 0 || age > time.Minute {
+		t.Errorf("timestamp is %s old", age)
+	}
+
+	// verify the signature as a receiver would
+	signature, ok := strings.CutPrefix(received.Header.Get(SignatureHeader), "sha256=")
+	if !ok {
+		t.Fatalf("signature header = %q, want a sha256= prefix", received.Header.Get(SignatureHeader))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(received.Header.Get(TimestampHeader) + "."))
+	mac.Write(receivedBody)
+	expected := mac.Sum(nil)
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, expected) {
+		t.Errorf("signature %s doesn't verify", signature)
+	}
+}
+
+func TestSenderSendFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	sender := newSender(5*time.Second, nil)
+
+	result, err := sender.Send(srv.URL, "whsec_test", "movie.created", 1, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OK() || result.StatusCode != http.StatusInternalServerError {
+		t.Errorf("result = %+v, want a failed 500", result)
+	}
+
+	// redirects are not followed
+	result, err = sender.Send(srv.URL+"/redirect", "whsec_test", "movie.created", 1, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OK() || result.StatusCode != http.StatusFound {
+		t.Errorf("result = %+v, want an unfollowed 302", result)
+	}
+}
+
+func TestSenderRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	result, err := New(5*time.Second).Send(srv.URL, "whsec_test", "movie.created", 1, []byte(`{}`))
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("err = %v, want ErrPrivateAddress", err)
+	}
+	if called || result.StatusCode != 0 {
+		t.Error("the private address must not be reached")
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"192.168.0.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"::", true},
+		{"fe80::1", true},
+		{"fd12:3456::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"93.184.216.34", false},
+		{"172.32.0.1", false},
+		{"2606:2800:220:1::1", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPrivateIP(net.ParseIP(tt.ip)); got != tt.private {
+			t.Errorf("IsPrivateIP(%s) = %t, want %t", tt.ip, got, tt.private)
+		}
+	}
+}
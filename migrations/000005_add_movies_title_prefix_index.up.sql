@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent() is only STABLE, wrap it so it can be used in an index expression
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text AS
+$$
+SELECT public.unaccent('public.unaccent', $1)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+CREATE INDEX IF NOT EXISTS movies_title_prefix_idx ON movies (lower(immutable_unaccent(title)) text_pattern_ops);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS genres
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug       text UNIQUE                 NOT NULL,
+    name       text                        NOT NULL,
+    aliases    text[]                      NOT NULL DEFAULT '{}',
+    version    integer                     NOT NULL DEFAULT 1
+);
+
+INSERT INTO genres (slug, name, aliases)
+VALUES ('action', 'Action', '{}'),
+       ('adventure', 'Adventure', '{}'),
+       ('animation', 'Animation', '{animated,cartoon}'),
+       ('comedy', 'Comedy', '{}'),
+       ('crime', 'Crime', '{}'),
+       ('documentary', 'Documentary', '{doc}'),
+       ('drama', 'Drama', '{}'),
+       ('family', 'Family', '{}'),
+       ('fantasy', 'Fantasy', '{}'),
+       ('history', 'History', '{historical}'),
+       ('horror', 'Horror', '{}'),
+       ('music', 'Music', '{musical}'),
+       ('mystery', 'Mystery', '{}'),
+       ('romance', 'Romance', '{romantic}'),
+       ('science-fiction', 'Science Fiction', '{sci-fi,scifi,sf}'),
+       ('thriller', 'Thriller', '{}'),
+       ('war', 'War', '{}'),
+       ('western', 'Western', '{}')
+ON CONFLICT (slug) DO NOTHING;
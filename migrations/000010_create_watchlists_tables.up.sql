@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS watchlist_items
+(
+    id       bigserial PRIMARY KEY,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id  bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer                     NOT NULL,
+    priority integer                     NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 5),
+    notes    text                        NOT NULL DEFAULT '',
+    UNIQUE (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_items_user_id_position_idx ON watchlist_items (user_id, position);
+
+CREATE TABLE IF NOT EXISTS watched_movies
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id    bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id   bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    watched_at date                        NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS watched_movies_user_id_watched_at_idx ON watched_movies (user_id, watched_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_list_items;
+DROP TABLE IF EXISTS movie_lists;
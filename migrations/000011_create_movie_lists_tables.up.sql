@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS movie_lists
+(
+    id          bigserial PRIMARY KEY,
+    created_at  timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id     bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    slug        text                        NOT NULL UNIQUE,
+    title       text                        NOT NULL,
+    description text                        NOT NULL DEFAULT '',
+    visibility  text                        NOT NULL DEFAULT 'private' CHECK (visibility IN ('private', 'unlisted', 'public')),
+    version     integer                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS movie_lists_user_id_idx ON movie_lists (user_id);
+CREATE INDEX IF NOT EXISTS movie_lists_title_idx ON movie_lists USING GIN (to_tsvector('simple', title));
+
+CREATE TABLE IF NOT EXISTS movie_list_items
+(
+    list_id  bigint                      NOT NULL REFERENCES movie_lists ON DELETE CASCADE,
+    movie_id bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    position integer                     NOT NULL,
+    PRIMARY KEY (list_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_list_items_list_id_position_idx ON movie_list_items (list_id, position);
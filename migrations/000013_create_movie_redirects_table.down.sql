@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_redirects;
+DROP INDEX IF EXISTS movies_title_trgm_idx;
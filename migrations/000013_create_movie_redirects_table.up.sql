@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS movies_title_trgm_idx ON movies USING GIN (lower(immutable_unaccent(title)) gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS movie_redirects
+(
+    old_id     bigint PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id   bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS movie_redirects_movie_id_idx ON movie_redirects (movie_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids
+(
+    movie_id    bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source      text                        NOT NULL CHECK (source IN ('imdb', 'tmdb', 'wikidata')),
+    external_id text                        NOT NULL,
+    created_at  timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, source),
+    UNIQUE (source, external_id)
+);
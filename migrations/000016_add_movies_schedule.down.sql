@@ -0,0 +1,3 @@
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_schedule_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE movies DROP COLUMN IF EXISTS publish_at;
@@ -0,0 +1,7 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS unpublish_at timestamp(0) with time zone;
+
+ALTER TABLE movies ADD CONSTRAINT movies_schedule_check CHECK (unpublish_at > publish_at);
+
+CREATE INDEX IF NOT EXISTS movies_publish_at_idx ON movies (publish_at) WHERE status = 'in_review';
+CREATE INDEX IF NOT EXISTS movies_unpublish_at_idx ON movies (unpublish_at) WHERE status = 'published';
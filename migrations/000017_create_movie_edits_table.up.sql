@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS movie_edits
+(
+    id           bigserial PRIMARY KEY,
+    created_at   timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id     bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id      bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    base_version integer                     NOT NULL,
+    patch        jsonb                       NOT NULL,
+    comment      text                        NOT NULL DEFAULT '',
+    status       text                        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    reviewed_by  bigint REFERENCES users ON DELETE SET NULL,
+    reviewed_at  timestamp(0) with time zone,
+    reason       text                        NOT NULL DEFAULT '',
+    version      integer                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS movie_edits_movie_id_idx ON movie_edits (movie_id);
+CREATE INDEX IF NOT EXISTS movie_edits_pending_idx ON movie_edits (created_at) WHERE status = 'pending';
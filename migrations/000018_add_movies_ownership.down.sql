@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'movies:admin';
+
+ALTER TABLE movies DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE movies DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,13 @@
+-- movie_id has no foreign key, the tombstones of deleted movies must outlive them
+CREATE TABLE IF NOT EXISTS movie_changes
+(
+    seq        bigserial PRIMARY KEY,
+    movie_id   bigint                      NOT NULL,
+    version    integer                     NOT NULL,
+    kind       text                        NOT NULL CHECK (kind IN ('created', 'updated', 'deleted')),
+    changed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- movies which existed before the change feed are reported as created
+INSERT INTO movie_changes (movie_id, version, kind)
+SELECT id, version, 'created' FROM movies ORDER BY id;
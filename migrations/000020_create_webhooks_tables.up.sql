@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id    bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    url        text                        NOT NULL,
+    events     text[]                      NOT NULL,
+    secret     text                        NOT NULL,
+    active     boolean                     NOT NULL DEFAULT true,
+    version    integer                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               bigserial PRIMARY KEY,
+    created_at       timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    webhook_id       bigint                      NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+    event            text                        NOT NULL,
+    payload          jsonb                       NOT NULL,
+    status           text                        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempt_count    integer                     NOT NULL DEFAULT 0,
+    next_attempt_at  timestamp with time zone    NOT NULL DEFAULT NOW(),
+    last_status_code integer,
+    last_latency_ms  integer,
+    last_error       text                        NOT NULL DEFAULT '',
+    delivered_at     timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhook_attempts
+(
+    id           bigserial PRIMARY KEY,
+    delivery_id  bigint                      NOT NULL REFERENCES webhook_deliveries ON DELETE CASCADE,
+    attempted_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    status_code  integer,
+    latency_ms   integer                     NOT NULL,
+    error        text                        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_id_idx ON webhook_attempts (delivery_id);
+
+INSERT INTO permissions (code)
+VALUES ('webhooks:write')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS movies_publish_pending_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS publish_pending;
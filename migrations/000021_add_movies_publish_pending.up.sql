@@ -0,0 +1,5 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS publish_pending boolean NOT NULL DEFAULT false;
+
+UPDATE movies SET publish_pending = true WHERE status = 'published' AND publish_at > NOW();
+
+CREATE INDEX IF NOT EXISTS movies_publish_pending_idx ON movies (publish_at) WHERE publish_pending;